@@ -0,0 +1,4 @@
+// Package interop checks that the hello example server works with the
+// go-redis client. It is its own module so that the main module does not
+// depend on go-redis; run its tests from this directory with go test.
+package interop
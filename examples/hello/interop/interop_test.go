@@ -0,0 +1,125 @@
+package interop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// startHello builds the hello example and runs it on a free local port,
+// returning its address
+func startHello(t *testing.T) string {
+	t.Helper()
+
+	bin := filepath.Join(t.TempDir(), "hello")
+	build := exec.Command("go", "build", "-o", bin, "./examples/hello")
+	build.Dir = filepath.Join("..", "..", "..")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build hello example: %v\n%s", err, out)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	srv := exec.Command(bin, "-addr", addr)
+	srv.Stderr = os.Stderr
+	if err := srv.Start(); err != nil {
+		t.Fatalf("start hello example: %v", err)
+	}
+	t.Cleanup(func() {
+		srv.Process.Kill()
+		srv.Wait()
+	})
+
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		c, err := net.Dial("tcp", addr)
+		if err == nil {
+			c.Close()
+			return addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hello example not listening on %s: %v", addr, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestGoRedis(t *testing.T) {
+	addr := startHello(t)
+
+	for _, protocol := range []int{2, 3} {
+		t.Run(fmt.Sprintf("RESP%d", protocol), func(t *testing.T) {
+			ctx := context.Background()
+			rdb := redis.NewClient(&redis.Options{Addr: addr, Protocol: protocol})
+			defer rdb.Close()
+
+			hello, err := rdb.Do(ctx, "HELLO", protocol).Result()
+			if err != nil {
+				t.Fatalf("HELLO: %v", err)
+			}
+			if proto := helloField(t, hello, "proto"); proto != int64(protocol) {
+				t.Errorf("HELLO proto = %v, want %d", proto, protocol)
+			}
+			if server := helloField(t, hello, "server"); server != "goluxis" {
+				t.Errorf("HELLO server = %v, want goluxis", server)
+			}
+
+			if pong, err := rdb.Ping(ctx).Result(); err != nil || pong != "PONG" {
+				t.Errorf("PING = %q, %v, want PONG", pong, err)
+			}
+
+			greeting, err := rdb.Do(ctx, "hello.world", "Goluxis").Text()
+			if err != nil || greeting != "Hello, Goluxis!" {
+				t.Errorf("HELLO.WORLD = %q, %v, want %q", greeting, err, "Hello, Goluxis!")
+			}
+
+			conn := rdb.Conn()
+			defer conn.Close()
+			if _, err := conn.ClientGetName(ctx).Result(); !errors.Is(err, redis.Nil) {
+				t.Errorf("CLIENT GETNAME error = %v, want redis.Nil", err)
+			}
+			if ok, err := conn.ClientSetName(ctx, "interop").Result(); err != nil || !ok {
+				t.Errorf("CLIENT SETNAME = %v, %v, want OK", ok, err)
+			}
+			if name, err := conn.ClientGetName(ctx).Result(); err != nil || name != "interop" {
+				t.Errorf("CLIENT GETNAME = %q, %v, want interop", name, err)
+			}
+
+			if _, err := rdb.Do(ctx, "NO.SUCH.COMMAND").Result(); err == nil {
+				t.Error("unknown command succeeded")
+			}
+		})
+	}
+}
+
+// helloField returns a field of a HELLO reply, which is a map in RESP3 and
+// a flat list of pairs in RESP2
+func helloField(t *testing.T, reply interface{}, field string) interface{} {
+	t.Helper()
+
+	switch r := reply.(type) {
+	case map[interface{}]interface{}:
+		return r[field]
+	case []interface{}:
+		for i := 0; i+1 < len(r); i += 2 {
+			if r[i] == field {
+				return r[i+1]
+			}
+		}
+		return nil
+	}
+	t.Fatalf("HELLO reply has type %T", reply)
+	return nil
+}
@@ -1,10 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"log"
-	"net"
+	"math"
 	"os"
 	"os/signal"
 	"strconv"
@@ -13,7 +13,8 @@ import (
 	"time"
 
 	"github.com/aakash-a-dev/Goluxis/pkg/command"
-	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+	"github.com/aakash-a-dev/Goluxis/pkg/server"
+	"github.com/aakash-a-dev/Goluxis/pkg/store"
 )
 
 // Window represents a time window for rate limiting
@@ -22,23 +23,70 @@ type Window struct {
 	Count     int64
 }
 
-// RateLimiter implements a sliding window rate limiter
+// DefaultSweepInterval is how often expired windows are dropped unless
+// RateLimiter.SweepInterval is set
+const DefaultSweepInterval = time.Minute
+
+// RateLimiter implements a sliding window rate limiter. Each key is locked
+// on its own, so requests for different keys do not wait for each other.
+// Windows older than an hour are dropped by a background sweeper, keeping
+// the per-request path free of cleanup work.
 type RateLimiter struct {
-	windows map[string][]Window
-	mu      sync.RWMutex
+	SweepInterval time.Duration
+
+	windows *store.Store[[]Window]
+	locks   *store.KeyedMutex
+	done    chan struct{}
 }
 
 func NewRateLimiter() *RateLimiter {
 	return &RateLimiter{
-		windows: make(map[string][]Window),
+		SweepInterval: DefaultSweepInterval,
+		windows:       store.New[[]Window](),
+		locks:         store.NewKeyedMutex(256),
+	}
+}
+
+// Start launches the sweeper, which runs until ctx is canceled
+func (rl *RateLimiter) Start(ctx context.Context) error {
+	rl.done = make(chan struct{})
+	go rl.run(ctx)
+	return nil
+}
+
+// Stop waits for the sweeper to exit after its context was canceled
+func (rl *RateLimiter) Stop() error {
+	if rl.done != nil {
+		<-rl.done
 	}
+	return nil
 }
 
+// run sweeps every SweepInterval until ctx is canceled
+func (rl *RateLimiter) run(ctx context.Context) {
+	defer close(rl.done)
+
+	ticker := time.NewTicker(rl.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, key := range rl.windows.Keys() {
+				rl.cleanup(key)
+			}
+		}
+	}
+}
+
+// cleanup drops the windows of key older than an hour
 func (rl *RateLimiter) cleanup(key string) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+	rl.locks.Lock(key)
+	defer rl.locks.Unlock(key)
 
-	if windows, exists := rl.windows[key]; exists {
+	if windows, exists := rl.windows.Get(key); exists {
 		now := time.Now()
 		var active []Window
 		for _, w := range windows {
@@ -47,16 +95,86 @@ func (rl *RateLimiter) cleanup(key string) {
 			}
 		}
 		if len(active) == 0 {
-			delete(rl.windows, key)
+			rl.windows.Delete(key)
 		} else {
-			rl.windows[key] = active
+			rl.windows.Set(key, active)
 		}
 	}
 }
 
+// TokenBucket holds the state of one token bucket. Only the token count and
+// the time of the last refill are stored, so memory and time per request
+// are constant regardless of the request rate.
+type TokenBucket struct {
+	Tokens     float64
+	LastRefill time.Time
+	Rate       float64 // tokens added per second
+	Burst      float64 // bucket capacity
+}
+
+// refill adds the tokens accrued since the last refill, up to the capacity
+func (b *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.LastRefill).Seconds()
+	if elapsed > 0 {
+		b.Tokens = math.Min(b.Burst, b.Tokens+elapsed*b.Rate)
+		b.LastRefill = now
+	}
+}
+
+// TokenLimiter implements a token bucket rate limiter
+type TokenLimiter struct {
+	buckets map[string]*TokenBucket
+	mu      sync.Mutex
+}
+
+func NewTokenLimiter() *TokenLimiter {
+	return &TokenLimiter{
+		buckets: make(map[string]*TokenBucket),
+	}
+}
+
+// Take refills the bucket for key and removes one token if available. New
+// buckets start full. It returns whether the request is allowed, the whole
+// tokens left and, if denied, how long until a token is available.
+func (tl *TokenLimiter) Take(key string, rate, burst float64) (bool, int64, time.Duration) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	now := time.Now()
+	bucket, exists := tl.buckets[key]
+	if !exists {
+		bucket = &TokenBucket{Tokens: burst, LastRefill: now}
+		tl.buckets[key] = bucket
+	}
+	bucket.Rate = rate
+	bucket.Burst = burst
+	bucket.refill(now)
+
+	if bucket.Tokens < 1 {
+		wait := time.Duration((1 - bucket.Tokens) / rate * float64(time.Second))
+		return false, 0, wait
+	}
+	bucket.Tokens--
+	return true, int64(bucket.Tokens), 0
+}
+
+// Get returns a refilled copy of the bucket for key
+func (tl *TokenLimiter) Get(key string) (TokenBucket, bool) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	bucket, exists := tl.buckets[key]
+	if !exists {
+		return TokenBucket{}, false
+	}
+	bucket.refill(time.Now())
+	return *bucket, true
+}
+
 func main() {
-	// Create rate limiter
+	// Create rate limiters
 	limiter := NewRateLimiter()
+	tokens := NewTokenLimiter()
 
 	// Create extension
 	ext := command.NewExtension("rate-limiter")
@@ -64,11 +182,11 @@ func main() {
 	// RATELIMIT.ALLOW command
 	allowCmd := command.New("RATELIMIT.ALLOW")
 	allowCmd.Description = "Check if request is allowed under rate limit"
+	allowCmd.MinArgs = 3
+	allowCmd.MaxArgs = 3
+	allowCmd.ArgNames = []string{"key", "max_requests", "window_seconds"}
+	allowCmd.Keys = &command.KeySpec{First: 1, Last: 1, Step: 1}
 	allowCmd.Handler = func(ctx *command.Context) error {
-		if len(ctx.Args) != 4 {
-			return fmt.Errorf("usage: RATELIMIT.ALLOW <key> <max_requests> <window_seconds>")
-		}
-
 		key := ctx.Args[1]
 		maxRequests, err := strconv.ParseInt(ctx.Args[2], 10, 64)
 		if err != nil {
@@ -80,199 +198,150 @@ func main() {
 			return fmt.Errorf("invalid window_seconds: %v", err)
 		}
 
-		// Cleanup old windows
-		limiter.cleanup(key)
+		// Hold the key while checking and recording so that concurrent
+		// requests cannot both take the last slot
+		limiter.locks.Lock(key)
+		defer limiter.locks.Unlock(key)
 
 		now := time.Now()
 		windowDuration := time.Duration(windowSeconds) * time.Second
 
 		// Calculate total requests in the current window
-		limiter.mu.RLock()
-		windows := limiter.windows[key]
+		windows, _ := limiter.windows.Get(key)
 		var totalRequests int64
+		var oldest time.Time
 		for _, w := range windows {
 			if now.Sub(w.Timestamp) < windowDuration {
+				if totalRequests == 0 {
+					oldest = w.Timestamp
+				}
 				totalRequests += w.Count
 			}
 		}
-		limiter.mu.RUnlock()
 
 		if totalRequests >= maxRequests {
-			return ctx.Reply("0") // Not allowed
+			// Tell RESP3 clients when the oldest request leaves the window
+			if !oldest.IsZero() {
+				retryAfter := windowDuration - now.Sub(oldest)
+				ctx.SetReplyAttribute("retry-after", int64(math.Ceil(retryAfter.Seconds())))
+			}
+			ctx.SetReplyAttribute("remaining", int64(0))
+			return ctx.ReplyBool(false)
 		}
 
 		// Add new request to window
-		limiter.mu.Lock()
-		if _, exists := limiter.windows[key]; !exists {
-			limiter.windows[key] = make([]Window, 0)
-		}
-		limiter.windows[key] = append(limiter.windows[key], Window{
+		limiter.windows.Set(key, append(windows, Window{
 			Timestamp: now,
 			Count:     1,
-		})
-		limiter.mu.Unlock()
+		}))
 
-		return ctx.Reply("1") // Allowed
+		ctx.SetReplyAttribute("remaining", maxRequests-totalRequests-1)
+		return ctx.ReplyBool(true)
+	}
+
+	// RATELIMIT.TOKEN command
+	tokenCmd := command.New("RATELIMIT.TOKEN")
+	tokenCmd.Description = "Check if request is allowed by a token bucket"
+	tokenCmd.MinArgs = 3
+	tokenCmd.MaxArgs = 3
+	tokenCmd.ArgNames = []string{"key", "rate", "burst"}
+	tokenCmd.Keys = &command.KeySpec{First: 1, Last: 1, Step: 1}
+	tokenCmd.Handler = func(ctx *command.Context) error {
+		key := ctx.Args[1]
+		rate, err := strconv.ParseFloat(ctx.Args[2], 64)
+		if err != nil || !(rate > 0) || math.IsInf(rate, 1) {
+			return fmt.Errorf("invalid rate: must be a positive number of tokens per second")
+		}
+
+		burst, err := strconv.ParseInt(ctx.Args[3], 10, 64)
+		if err != nil || burst < 1 {
+			return fmt.Errorf("invalid burst: must be a positive integer")
+		}
+
+		allowed, remaining, wait := tokens.Take(key, rate, float64(burst))
+		ctx.SetReplyAttribute("remaining", remaining)
+		if !allowed {
+			ctx.SetReplyAttribute("retry-after", int64(math.Ceil(wait.Seconds())))
+			return ctx.ReplyBool(false)
+		}
+		return ctx.ReplyBool(true)
 	}
 
 	// RATELIMIT.INFO command
 	infoCmd := command.New("RATELIMIT.INFO")
 	infoCmd.Description = "Get rate limit information for a key"
+	infoCmd.MinArgs = 1
+	infoCmd.MaxArgs = 1
+	infoCmd.ArgNames = []string{"key"}
+	infoCmd.Keys = &command.KeySpec{First: 1, Last: 1, Step: 1}
 	infoCmd.Handler = func(ctx *command.Context) error {
-		if len(ctx.Args) != 2 {
-			return fmt.Errorf("usage: RATELIMIT.INFO <key>")
-		}
-
 		key := ctx.Args[1]
 
-		// Cleanup old windows
-		limiter.cleanup(key)
+		if bucket, exists := tokens.Get(key); exists {
+			info := fmt.Sprintf(`{
+			"key": "%s",
+			"algorithm": "token-bucket",
+			"tokens": %.2f,
+			"rate": %g,
+			"burst": %g
+		}`, key, bucket.Tokens, bucket.Rate, bucket.Burst)
 
-		limiter.mu.RLock()
-		windows := limiter.windows[key]
-		var totalRequests int64
+			return ctx.Reply(info)
+		}
+
+		limiter.locks.RLock(key)
+		windows, _ := limiter.windows.Get(key)
+		var totalRequests, windowCount int64
 		now := time.Now()
 
+		// Windows older than an hour may linger until the next sweep
 		for _, w := range windows {
 			if now.Sub(w.Timestamp) < time.Hour {
 				totalRequests += w.Count
+				windowCount++
 			}
 		}
-		limiter.mu.RUnlock()
+		limiter.locks.RUnlock(key)
 
 		info := fmt.Sprintf(`{
 			"key": "%s",
+			"algorithm": "sliding-window",
 			"total_requests": %d,
 			"window_count": %d
-		}`, key, totalRequests, len(windows))
+		}`, key, totalRequests, windowCount)
 
 		return ctx.Reply(info)
 	}
 
 	// Register commands
-	ext.AddCommand(allowCmd)
-	ext.AddCommand(infoCmd)
-
-	// Start TCP server
-	listener, err := net.Listen("tcp", ":6380")
-	if err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if err := ext.AddCommands(allowCmd, tokenCmd, infoCmd); err != nil {
+		log.Fatalf("Failed to register commands: %v", err)
 	}
-	defer listener.Close()
 
-	log.Printf("Rate limiter extension listening on :6380")
+	// Sweep expired windows in the background while serving
+	ext.OnStart(limiter.Start)
+	ext.OnStop(limiter.Stop)
+
+	// Start server
+	srv := server.New(ext)
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	stopped := make(chan struct{})
 	go func() {
 		<-sigChan
 		log.Println("Shutting down...")
-		listener.Close()
-	}()
-
-	// Accept connections
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			if opErr, ok := err.(*net.OpError); ok && opErr.Err.Error() == "use of closed network connection" {
-				break
-			}
-			log.Printf("Failed to accept connection: %v", err)
-			continue
-		}
-
-		go handleConnection(conn, ext)
-	}
-}
-
-func handleConnection(conn net.Conn, ext *command.Extension) {
-	defer conn.Close()
-
-	reader := resp.NewReader(conn)
-	writer := resp.NewWriter(conn)
-	rConn := &redisConn{writer: writer}
-
-	for {
-		// Read command
-		obj, err := reader.ReadObject()
-		if err != nil {
-			if err != io.EOF {
-				log.Printf("Error reading command: %v", err)
-			}
-			return
-		}
-
-		// Parse command array
-		cmdArray, ok := obj.([]interface{})
-		if !ok {
-			rConn.WriteError(fmt.Errorf("invalid command format"))
-			continue
-		}
-
-		if len(cmdArray) == 0 {
-			rConn.WriteError(fmt.Errorf("empty command"))
-			continue
-		}
-
-		// Get command name
-		cmdName, ok := cmdArray[0].(string)
-		if !ok {
-			rConn.WriteError(fmt.Errorf("invalid command name"))
-			continue
-		}
-
-		// Get command
-		cmd, err := ext.GetCommand(cmdName)
-		if err != nil {
-			rConn.WriteError(err)
-			continue
-		}
-
-		// Convert arguments to strings
-		args := make([]string, len(cmdArray))
-		for i, arg := range cmdArray {
-			args[i] = fmt.Sprint(arg)
-		}
-
-		// Create context
-		ctx := &command.Context{
-			Args: args,
-			Conn: rConn,
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Printf("Shutdown error: %v", err)
 		}
+		close(stopped)
+	}()
 
-		// Execute command
-		if err := cmd.Handler(ctx); err != nil {
-			rConn.WriteError(err)
-		}
+	log.Printf("Rate limiter extension listening on :6380")
+	if err := srv.ListenAndServe(":6380"); err != server.ErrServerClosed {
+		log.Fatalf("Failed to start server: %v", err)
 	}
-}
-
-type redisConn struct {
-	writer *resp.Writer
-}
-
-func (c *redisConn) WriteString(s string) error {
-	return c.writer.WriteBulkString(s)
-}
-
-func (c *redisConn) WriteInt(i int64) error {
-	return c.writer.WriteInteger(i)
-}
-
-func (c *redisConn) WriteArray(length int) error {
-	return c.writer.WriteArray(length)
-}
-
-func (c *redisConn) WriteNull() error {
-	return c.writer.WriteBulkString("")
-}
-
-func (c *redisConn) WriteError(err error) error {
-	return c.writer.WriteError(err)
-}
-
-func (c *redisConn) Flush() error {
-	return nil
+	<-stopped
 }
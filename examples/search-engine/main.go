@@ -1,20 +1,23 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"os"
 	"os/signal"
-	"strconv"
+	"sort"
 	"strings"
-	"sync"
 	"syscall"
+	"time"
+	"unicode"
 
 	"github.com/aakash-a-dev/Goluxis/pkg/command"
-	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+	"github.com/aakash-a-dev/Goluxis/pkg/persist"
+	"github.com/aakash-a-dev/Goluxis/pkg/server"
+	"github.com/aakash-a-dev/Goluxis/pkg/store"
 )
 
 // Product represents a product in our catalog
@@ -25,24 +28,54 @@ type Product struct {
 	Category string   `json:"category"`
 	Price    float64  `json:"price"`
 	Tags     []string `json:"tags"`
-	Score    float64  `json:"score"`
+	Score    float64  `json:"score,omitempty"` // set on search results only
+}
+
+// SearchPage is one page of search results along with the total number
+// of matches
+type SearchPage struct {
+	Total   int       `json:"total"`
+	Results []Product `json:"results"`
 }
 
 // ProductStore is our in-memory product database
 type ProductStore struct {
-	products map[string]Product
-	mu       sync.RWMutex
+	products *store.Store[Product]
 }
 
 func NewProductStore() *ProductStore {
 	return &ProductStore{
-		products: make(map[string]Product),
+		products: store.New[Product]().TrackAccess(),
+	}
+}
+
+// Save writes the catalog to w as JSON
+func (s *ProductStore) Save(w io.Writer) error {
+	products := make(map[string]Product)
+	s.products.Range(func(id string, product Product) bool {
+		products[id] = product
+		return true
+	})
+	return persist.JSON(products).Save(w)
+}
+
+// Load replaces the catalog with the JSON snapshot read from r
+func (s *ProductStore) Load(r io.Reader) error {
+	products := make(map[string]Product)
+	if err := persist.JSON(&products).Load(r); err != nil {
+		return err
 	}
+
+	s.products.Clear()
+	for id, product := range products {
+		s.products.Set(id, product)
+	}
+	return nil
 }
 
 func main() {
 	// Create product store
-	store := NewProductStore()
+	catalog := NewProductStore()
 
 	// Create extension
 	ext := command.NewExtension("product-search")
@@ -50,11 +83,12 @@ func main() {
 	// PRODUCT.ADD command
 	addCmd := command.New("PRODUCT.ADD")
 	addCmd.Description = "Add a product to the catalog"
+	addCmd.Flags = command.FlagWrite
+	addCmd.MinArgs = 2
+	addCmd.MaxArgs = 2
+	addCmd.ArgNames = []string{"id", "json"}
+	addCmd.Keys = &command.KeySpec{First: 1, Last: 1, Step: 1}
 	addCmd.Handler = func(ctx *command.Context) error {
-		if len(ctx.Args) != 3 {
-			return fmt.Errorf("usage: PRODUCT.ADD <id> <json_data>")
-		}
-
 		id := ctx.Args[1]
 		jsonData := ctx.Args[2]
 
@@ -64,197 +98,244 @@ func main() {
 		}
 
 		product.ID = id
-		store.mu.Lock()
-		store.products[id] = product
-		store.mu.Unlock()
+		catalog.products.Set(id, product)
 
-		return ctx.Reply("OK")
+		return ctx.ReplyStatus("OK")
+	}
+
+	// PRODUCT.GET command
+	getCmd := command.New("PRODUCT.GET")
+	getCmd.Description = "Get a product by ID"
+	getCmd.Flags = command.FlagReadOnly
+	getCmd.MinArgs = 1
+	getCmd.MaxArgs = 1
+	getCmd.ArgNames = []string{"id"}
+	getCmd.Keys = &command.KeySpec{First: 1, Last: 1, Step: 1}
+	getCmd.Handler = func(ctx *command.Context) error {
+		product, exists := catalog.products.Get(ctx.Args[1])
+		if !exists {
+			return ctx.ReplyNotFound()
+		}
+		return ctx.ReplyStruct(product)
+	}
+
+	// PRODUCT.TAGS command
+	tagsCmd := command.New("PRODUCT.TAGS")
+	tagsCmd.Description = "Get the tags of a product as a set"
+	tagsCmd.Flags = command.FlagReadOnly
+	tagsCmd.MinArgs = 1
+	tagsCmd.MaxArgs = 1
+	tagsCmd.ArgNames = []string{"id"}
+	tagsCmd.Keys = &command.KeySpec{First: 1, Last: 1, Step: 1}
+	tagsCmd.Handler = func(ctx *command.Context) error {
+		product, exists := catalog.products.Get(ctx.Args[1])
+		if !exists {
+			return ctx.ReplyNotFound()
+		}
+		return ctx.ReplyStringSet(product.Tags)
 	}
 
 	// PRODUCT.SEARCH command
 	searchCmd := command.New("PRODUCT.SEARCH")
 	searchCmd.Description = "Search products with filters"
+	searchCmd.MinArgs = 1
+	searchCmd.ArgNames = []string{"query", "[filter=value ...]", "[LIMIT offset count]"}
 	searchCmd.Handler = func(ctx *command.Context) error {
-		if len(ctx.Args) < 2 {
-			return fmt.Errorf("usage: PRODUCT.SEARCH <query> [brand=X] [category=Y] [min_price=N] [max_price=M]")
+		offset, count, args, err := command.ParseLimit(ctx.Args[2:])
+		if err != nil {
+			return err
 		}
 
-		query := strings.ToLower(ctx.Args[1])
-		filters := make(map[string]string)
+		opts, err := command.ParseOptions(args, filterOptions)
+		if err != nil {
+			return err
+		}
 
-		// Parse filters
-		for _, arg := range ctx.Args[2:] {
-			parts := strings.SplitN(arg, "=", 2)
-			if len(parts) == 2 {
-				filters[strings.ToLower(parts[0])] = strings.ToLower(parts[1])
-			}
+		results := catalog.Search(tokenize(ctx.Args[1]), opts)
+		if len(results) == 0 {
+			return ctx.ReplyEmptyArray()
 		}
 
-		// Search and filter products
-		var results []Product
-		store.mu.RLock()
-		for _, product := range store.products {
-			// Basic search matching
-			if !strings.Contains(strings.ToLower(product.Name), query) &&
-				!strings.Contains(strings.ToLower(product.Brand), query) {
-				continue
-			}
-
-			// Apply filters
-			if brand, ok := filters["brand"]; ok && strings.ToLower(product.Brand) != brand {
-				continue
-			}
-			if category, ok := filters["category"]; ok && strings.ToLower(product.Category) != category {
-				continue
-			}
-			if minPrice, ok := filters["min_price"]; ok {
-				if price, err := strconv.ParseFloat(minPrice, 64); err == nil && product.Price < price {
-					continue
-				}
-			}
-			if maxPrice, ok := filters["max_price"]; ok {
-				if price, err := strconv.ParseFloat(maxPrice, 64); err == nil && product.Price > price {
-					continue
-				}
-			}
+		start := min(offset, len(results))
+		end := start + min(count, len(results)-start)
+		page := SearchPage{
+			Total:   len(results),
+			Results: results[start:end],
+		}
 
-			results = append(results, product)
+		// Clients that negotiated compression get the page as compressed
+		// JSON, the others as a map they can read without decoding JSON
+		if ctx.Session == nil || !ctx.Session.Compression() {
+			return ctx.ReplyJSON(page)
+		}
+		jsonResults, err := json.Marshal(page)
+		if err != nil {
+			return err
+		}
+		return ctx.ReplyCompressed(jsonResults)
+	}
+
+	// PRODUCT.RANK command
+	rankCmd := command.New("PRODUCT.RANK")
+	rankCmd.Description = "Rank product IDs by relevance, optionally with scores"
+	rankCmd.Flags = command.FlagReadOnly
+	rankCmd.MinArgs = 1
+	rankCmd.ArgNames = []string{"query", "[filter=value ...]", "[LIMIT offset count]", "[WITHSCORES]"}
+	rankCmd.Handler = func(ctx *command.Context) error {
+		offset, count, args, err := command.ParseLimit(ctx.Args[2:])
+		if err != nil {
+			return err
 		}
-		store.mu.RUnlock()
 
-		// Convert results to JSON
-		jsonResults, err := json.Marshal(results)
+		opts, err := command.ParseOptions(args, rankOptions)
 		if err != nil {
 			return err
 		}
 
-		return ctx.Reply(string(jsonResults))
+		results := catalog.Search(tokenize(ctx.Args[1]), opts)
+
+		start := min(offset, len(results))
+		end := start + min(count, len(results)-start)
+		ids := make([]string, 0, end-start)
+		scores := make([]float64, 0, end-start)
+		for _, product := range results[start:end] {
+			ids = append(ids, product.ID)
+			scores = append(scores, product.Score)
+		}
+
+		return ctx.ReplyScoredMembers(ids, scores, opts.Has("WITHSCORES"))
 	}
 
 	// Register commands
-	ext.AddCommand(addCmd)
-	ext.AddCommand(searchCmd)
-
-	// Start TCP server
-	listener, err := net.Listen("tcp", ":6380")
-	if err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if err := ext.AddCommands(addCmd, getCmd, tagsCmd, searchCmd, rankCmd); err != nil {
+		log.Fatalf("Failed to register commands: %v", err)
 	}
-	defer listener.Close()
 
-	log.Printf("Product search engine listening on :6380")
+	// Start server
+	srv := server.New(ext)
+	srv.EnableSnapshots("products.json", catalog, 5*time.Minute)
+	srv.RegisterKeyspace("product", server.StoreKeyspace(catalog.products))
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	stopped := make(chan struct{})
 	go func() {
 		<-sigChan
 		log.Println("Shutting down...")
-		listener.Close()
-	}()
-
-	// Accept connections
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			if opErr, ok := err.(*net.OpError); ok && opErr.Err.Error() == "use of closed network connection" {
-				break
-			}
-			log.Printf("Failed to accept connection: %v", err)
-			continue
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Printf("Shutdown error: %v", err)
 		}
+		close(stopped)
+	}()
 
-		go handleConnection(conn, ext)
+	log.Printf("Product search engine listening on :6380")
+	if err := srv.ListenAndServe(":6380"); err != server.ErrServerClosed {
+		log.Fatalf("Failed to start server: %v", err)
 	}
+	<-stopped
 }
 
-func handleConnection(conn net.Conn, ext *command.Extension) {
-	defer conn.Close()
-
-	reader := resp.NewReader(conn)
-	writer := resp.NewWriter(conn)
-	rConn := &redisConn{writer: writer}
-
-	for {
-		// Read command
-		obj, err := reader.ReadObject()
-		if err != nil {
-			if err != io.EOF {
-				log.Printf("Error reading command: %v", err)
-			}
-			return
-		}
-
-		// Parse command array
-		cmdArray, ok := obj.([]interface{})
-		if !ok {
-			rConn.WriteError(fmt.Errorf("invalid command format"))
-			continue
-		}
-
-		if len(cmdArray) == 0 {
-			rConn.WriteError(fmt.Errorf("empty command"))
-			continue
+// Search returns the products passing the filters that match the query
+// terms, with their scores set, ordered by descending score. Ties break by
+// ID so that pages are stable.
+func (s *ProductStore) Search(terms []string, filters command.Options) []Product {
+	results := []Product{}
+	s.products.Range(func(_ string, product Product) bool {
+		if !matchesFilters(product, filters) {
+			return true
 		}
-
-		// Get command name
-		cmdName, ok := cmdArray[0].(string)
-		if !ok {
-			rConn.WriteError(fmt.Errorf("invalid command name"))
-			continue
-		}
-
-		// Get command
-		cmd, err := ext.GetCommand(cmdName)
-		if err != nil {
-			rConn.WriteError(err)
-			continue
-		}
-
-		// Convert arguments to strings
-		args := make([]string, len(cmdArray))
-		for i, arg := range cmdArray {
-			args[i] = fmt.Sprint(arg)
-		}
-
-		// Create context
-		ctx := &command.Context{
-			Args: args,
-			Conn: rConn,
+		if product.Score = relevance(product, terms); product.Score > 0 {
+			results = append(results, product)
 		}
+		return true
+	})
 
-		// Execute command
-		if err := cmd.Handler(ctx); err != nil {
-			rConn.WriteError(err)
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
 		}
-	}
+		return results[i].ID < results[j].ID
+	})
+	return results
 }
 
-type redisConn struct {
-	writer *resp.Writer
+// filterOptions are the field=value filters accepted by PRODUCT.SEARCH
+var filterOptions = []command.OptionSpec{
+	{Name: "brand", Style: command.AssignOption},
+	{Name: "category", Style: command.AssignOption},
+	{Name: "min_price", Style: command.AssignOption, Values: []command.ArgType{command.ArgFloat}},
+	{Name: "max_price", Style: command.AssignOption, Values: []command.ArgType{command.ArgFloat}},
 }
 
-func (c *redisConn) WriteString(s string) error {
-	return c.writer.WriteBulkString(s)
-}
+// rankOptions are the options accepted by PRODUCT.RANK: the filters and
+// WITHSCORES
+var rankOptions = append([]command.OptionSpec{
+	{Name: "WITHSCORES"},
+}, filterOptions...)
+
+// Field weights used by relevance. A query term that equals a whole word
+// scores higher than one that only appears inside a word, and the name
+// outweighs the brand.
+const (
+	nameExactWeight    = 3.0
+	brandExactWeight   = 2.0
+	namePartialWeight  = 1.0
+	brandPartialWeight = 0.5
+)
 
-func (c *redisConn) WriteInt(i int64) error {
-	return c.writer.WriteInteger(i)
+// tokenize splits s into lower-cased words of letters and digits
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
 }
 
-func (c *redisConn) WriteArray(length int) error {
-	return c.writer.WriteArray(length)
+// relevance scores a product against the query terms. Each term contributes
+// its best match in the name plus its best match in the brand. A score of
+// zero means the product does not match.
+func relevance(product Product, terms []string) float64 {
+	name := tokenize(product.Name)
+	brand := tokenize(product.Brand)
+
+	var score float64
+	for _, term := range terms {
+		score += fieldScore(name, term, nameExactWeight, namePartialWeight)
+		score += fieldScore(brand, term, brandExactWeight, brandPartialWeight)
+	}
+	return score
 }
 
-func (c *redisConn) WriteNull() error {
-	return c.writer.WriteBulkString("")
+// fieldScore returns the weight of the best match of term among words
+func fieldScore(words []string, term string, exact, partial float64) float64 {
+	best := 0.0
+	for _, word := range words {
+		if word == term {
+			return exact
+		}
+		if strings.Contains(word, term) {
+			best = partial
+		}
+	}
+	return best
 }
 
-func (c *redisConn) WriteError(err error) error {
-	return c.writer.WriteError(err)
-}
+// matchesFilters reports whether a product passes the brand, category and
+// price filters. Brand and category compare case-insensitively.
+func matchesFilters(product Product, filters command.Options) bool {
+	if filters.Has("brand") && !strings.EqualFold(product.Brand, filters.String("brand", 0)) {
+		return false
+	}
+	if filters.Has("category") && !strings.EqualFold(product.Category, filters.String("category", 0)) {
+		return false
+	}
+	if filters.Has("min_price") && product.Price < filters.Float("min_price", 0) {
+		return false
+	}
+	if filters.Has("max_price") && product.Price > filters.Float("max_price", 0) {
+		return false
+	}
 
-func (c *redisConn) Flush() error {
-	return nil
+	return true
 }
@@ -1,21 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"math"
-	"net"
 	"os"
 	"os/signal"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unsafe"
 
 	"github.com/aakash-a-dev/Goluxis/pkg/command"
-	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+	"github.com/aakash-a-dev/Goluxis/pkg/persist"
+	"github.com/aakash-a-dev/Goluxis/pkg/server"
+	"github.com/aakash-a-dev/Goluxis/pkg/store"
 )
 
 // TimeSeriesPoint represents a single data point
@@ -24,27 +33,495 @@ type TimeSeriesPoint struct {
 	Value     float64
 }
 
-// TimeSeries represents a collection of time series data
+// OutOfOrderPolicy decides what TS.ADD does with a point older than the
+// newest point of its series
+type OutOfOrderPolicy int
+
+const (
+	// InsertOutOfOrder inserts the point at its place in time
+	InsertOutOfOrder OutOfOrderPolicy = iota
+	// RejectOutOfOrder fails the TS.ADD with errOutOfOrder
+	RejectOutOfOrder
+)
+
+var (
+	errOutOfOrder       = errors.New("timestamp is older than the newest point of the series")
+	errInvalidTimestamp = errors.New("invalid timestamp, use milliseconds since the epoch, * or RFC3339")
+)
+
+// TimestampFormat decides how replies report timestamps
+type TimestampFormat int
+
+const (
+	// MillisecondTimestamps reports integer milliseconds since the Unix
+	// epoch, as RedisTimeSeries does
+	MillisecondTimestamps TimestampFormat = iota
+	// RFC3339Timestamps reports RFC3339 strings
+	RFC3339Timestamps
+)
+
+// format encodes t for a reply
+func (f TimestampFormat) format(t time.Time) interface{} {
+	if f == RFC3339Timestamps {
+		return t.Format(time.RFC3339)
+	}
+	return t.UnixMilli()
+}
+
+// parseTimestamp parses a timestamp argument: milliseconds since the Unix
+// epoch, "*" for now, or an RFC3339 timestamp
+func parseTimestamp(arg string, now time.Time) (time.Time, error) {
+	if arg == "*" {
+		return time.UnixMilli(now.UnixMilli()).UTC(), nil
+	}
+	if ms, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, arg)
+}
+
+// TimeSeries represents a collection of time series data. Points are kept
+// sorted by timestamp, points with equal timestamps in the order they were
+// added.
 type TimeSeries struct {
-	points []TimeSeriesPoint
-	mu     sync.RWMutex
+	points    []TimeSeriesPoint
+	retention time.Duration // zero keeps points forever
+	labels    map[string]string
+	mu        sync.RWMutex
+}
+
+// newTimeSeries returns a series holding points, sorting them if needed
+func newTimeSeries(points []TimeSeriesPoint, retention time.Duration) *TimeSeries {
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+	return &TimeSeries{points: points, retention: retention}
+}
+
+// add inserts a point after every point not newer than it. Points older
+// than the newest one are handled according to policy. The caller must
+// hold ts.mu.
+func (ts *TimeSeries) add(point TimeSeriesPoint, policy OutOfOrderPolicy) error {
+	i := ts.search(func(t time.Time) bool { return t.After(point.Timestamp) })
+	if i < len(ts.points) && policy == RejectOutOfOrder {
+		return errOutOfOrder
+	}
+	ts.points = slices.Insert(ts.points, i, point)
+	return nil
+}
+
+// search returns the index of the first point whose timestamp satisfies
+// after, which must be false for older points and true for newer ones
+func (ts *TimeSeries) search(after func(t time.Time) bool) int {
+	return sort.Search(len(ts.points), func(i int) bool {
+		return after(ts.points[i].Timestamp)
+	})
+}
+
+// between returns the points between the start and end bounds. The slice
+// shares the series' storage, so ts.mu must be held while it is used.
+func (ts *TimeSeries) between(start, end Bound) []TimeSeriesPoint {
+	lo := ts.search(func(t time.Time) bool {
+		return t.After(start.Time) || (!start.Exclusive && t.Equal(start.Time))
+	})
+	hi := ts.search(func(t time.Time) bool {
+		return t.After(end.Time) || (end.Exclusive && t.Equal(end.Time))
+	})
+	if hi < lo {
+		return nil
+	}
+	return ts.points[lo:hi]
+}
+
+// Bound is one end of a TS.RANGE query
+type Bound struct {
+	Time      time.Time
+	Exclusive bool
+}
+
+// parseBound parses a timestamp as parseTimestamp does, or "-" and "+" for
+// the earliest and latest possible times. A "(" prefix excludes it from the
+// range like the score bounds of ZRANGEBYSCORE.
+func parseBound(arg string) (Bound, error) {
+	var b Bound
+	if strings.HasPrefix(arg, "(") {
+		b.Exclusive = true
+		arg = arg[1:]
+	}
+
+	switch arg {
+	case "-":
+		b.Time = time.UnixMilli(math.MinInt64)
+	case "+":
+		b.Time = time.UnixMilli(math.MaxInt64)
+	default:
+		t, err := parseTimestamp(arg, time.Now())
+		if err != nil {
+			return Bound{}, err
+		}
+		b.Time = t
+	}
+	return b, nil
+}
+
+// expired reports whether t is outside the retention window at now
+func (ts *TimeSeries) expired(t, now time.Time) bool {
+	return ts.retention > 0 && t.Before(now.Add(-ts.retention))
 }
 
-// TimeSeriesStore stores multiple time series
+// trim drops the points outside the retention window, found by binary
+// search. Reslicing leaves the dropped points in the backing array, so once
+// they take up more than half of it the rest are copied to a new one. The
+// caller must hold ts.mu.
+func (ts *TimeSeries) trim(now time.Time) {
+	if ts.retention <= 0 {
+		return
+	}
+
+	cutoff := now.Add(-ts.retention)
+	i := ts.search(func(t time.Time) bool { return !t.Before(cutoff) })
+	if i > cap(ts.points)/2 {
+		ts.points = slices.Clone(ts.points[i:])
+		return
+	}
+	ts.points = ts.points[i:]
+}
+
+// ApproxSize implements store.Sizer. Points are counted by the capacity of
+// the slice holding them, since that is what is allocated.
+func (ts *TimeSeries) ApproxSize() int {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	return int(unsafe.Sizeof(*ts)) + cap(ts.points)*int(unsafe.Sizeof(TimeSeriesPoint{}))
+}
+
+// DefaultSweepInterval is how often points outside their series' retention
+// window are dropped unless TimeSeriesStore.SweepInterval is set
+const DefaultSweepInterval = time.Minute
+
+// TimeSeriesStore stores multiple time series. The series map is sharded
+// so that ingestion into different series does not contend on one lock.
 type TimeSeriesStore struct {
-	series map[string]*TimeSeries
-	mu     sync.RWMutex
+	SweepInterval time.Duration
+
+	// OutOfOrder decides what TS.ADD does with points older than the
+	// newest point of their series. The default inserts them in place.
+	OutOfOrder OutOfOrderPolicy
+
+	// Timestamps decides how replies report timestamps. The default is
+	// milliseconds since the Unix epoch.
+	Timestamps TimestampFormat
+
+	series *store.Store[*TimeSeries]
+	keys   *store.KeyedMutex // orders Add and Delete of the same key
+	done   chan struct{}
+
+	// index maps label name to label value to the keys of the series
+	// carrying that label
+	index   map[string]map[string]map[string]struct{}
+	indexMu sync.RWMutex
 }
 
 func NewTimeSeriesStore() *TimeSeriesStore {
 	return &TimeSeriesStore{
-		series: make(map[string]*TimeSeries),
+		SweepInterval: DefaultSweepInterval,
+		series:        store.New[*TimeSeries](),
+		keys:          store.NewKeyedMutex(store.DefaultShards),
+		index:         make(map[string]map[string]map[string]struct{}),
+	}
+}
+
+// Start launches the retention sweeper, which runs until ctx is canceled
+func (s *TimeSeriesStore) Start(ctx context.Context) error {
+	s.done = make(chan struct{})
+	go s.run(ctx)
+	return nil
+}
+
+// Stop waits for the sweeper to exit after its context was canceled
+func (s *TimeSeriesStore) Stop() error {
+	if s.done != nil {
+		<-s.done
+	}
+	return nil
+}
+
+// run trims every series every SweepInterval until ctx is canceled
+func (s *TimeSeriesStore) run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.series.Range(func(_ string, series *TimeSeries) bool {
+				series.mu.Lock()
+				series.trim(now)
+				series.mu.Unlock()
+				return true
+			})
+		}
+	}
+}
+
+// Get returns the series stored under key
+func (s *TimeSeriesStore) Get(key string) (*TimeSeries, bool) {
+	return s.series.Get(key)
+}
+
+// Exists reports whether a series is stored under key
+func (s *TimeSeriesStore) Exists(key string) bool {
+	return s.series.Exists(key)
+}
+
+// Delete removes the series stored under key and its labels from the index
+func (s *TimeSeriesStore) Delete(key string) bool {
+	s.keys.Lock(key)
+	defer s.keys.Unlock(key)
+
+	series, exists := s.series.Get(key)
+	if !exists || !s.series.Delete(key) {
+		return false
+	}
+
+	series.mu.RLock()
+	defer series.mu.RUnlock()
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	for name, value := range series.labels {
+		s.unindex(key, name, value)
+	}
+	return true
+}
+
+// Keys returns the keys of the series matching a redis-style glob pattern
+func (s *TimeSeriesStore) Keys(pattern string) []string {
+	return server.MatchKeys(s.series.Keys(), pattern)
+}
+
+// Len returns the number of series, reported in the server's state dump
+func (s *TimeSeriesStore) Len() int {
+	return s.series.Len()
+}
+
+// Add adds a point to the series stored under key, creating the series if
+// needed, and sets labels on it unless they are nil. The key is locked
+// throughout, so that a concurrent Delete cannot drop the series between
+// its lookup and the write, losing the point.
+func (s *TimeSeriesStore) Add(key string, point TimeSeriesPoint, labels map[string]string) error {
+	s.keys.Lock(key)
+	defer s.keys.Unlock(key)
+
+	series := s.GetOrCreate(key)
+	series.mu.Lock()
+	defer series.mu.Unlock()
+
+	now := time.Now()
+	if series.expired(point.Timestamp, now) {
+		return fmt.Errorf("timestamp is older than the series retention")
+	}
+	if err := series.add(point, s.OutOfOrder); err != nil {
+		return err
+	}
+	series.trim(now)
+	if labels != nil {
+		s.SetLabels(key, series, labels)
+	}
+	return nil
+}
+
+// GetOrCreate returns the series stored under key, creating it if needed
+func (s *TimeSeriesStore) GetOrCreate(key string) *TimeSeries {
+	if series, exists := s.series.Get(key); exists {
+		return series
+	}
+	series, _ := s.series.GetOrSet(key, newTimeSeries(make([]TimeSeriesPoint, 0), 0))
+	return series
+}
+
+// SetLabels sets labels on the series stored under key, keeping its other
+// labels, and indexes them for Match. The caller must hold series.mu.
+func (s *TimeSeriesStore) SetLabels(key string, series *TimeSeries, labels map[string]string) {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	if series.labels == nil {
+		series.labels = make(map[string]string, len(labels))
+	}
+	for name, value := range labels {
+		if old, exists := series.labels[name]; exists {
+			s.unindex(key, name, old)
+		}
+		series.labels[name] = value
+
+		values, exists := s.index[name]
+		if !exists {
+			values = make(map[string]map[string]struct{})
+			s.index[name] = values
+		}
+		if values[value] == nil {
+			values[value] = make(map[string]struct{})
+		}
+		values[value][key] = struct{}{}
+	}
+}
+
+// unindex removes key from the index entry of one label. The caller must
+// hold s.indexMu.
+func (s *TimeSeriesStore) unindex(key, name, value string) {
+	keys := s.index[name][value]
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(s.index[name], value)
+	}
+	if len(s.index[name]) == 0 {
+		delete(s.index, name)
+	}
+}
+
+// LabelMatcher is one FILTER condition of TS.MRANGE
+type LabelMatcher struct {
+	Label string
+	Value string
+	Equal bool // false matches series whose label is not Value
+}
+
+// matches reports whether labels satisfy the matcher. A missing label
+// counts as an empty value, as in RedisTimeSeries.
+func (m LabelMatcher) matches(labels map[string]string) bool {
+	return (labels[m.Label] == m.Value) == m.Equal
+}
+
+// parseFilter parses label=value and label!=value matchers. At least one
+// label=value matcher is required, since it is what the index is searched
+// with.
+func parseFilter(args []string) ([]LabelMatcher, error) {
+	var matchers []LabelMatcher
+	equal := false
+	for _, arg := range args {
+		m := LabelMatcher{Equal: true}
+		if label, value, ok := strings.Cut(arg, "!="); ok {
+			m.Label, m.Value, m.Equal = label, value, false
+		} else if label, value, ok := strings.Cut(arg, "="); ok {
+			m.Label, m.Value = label, value
+			equal = true
+		} else {
+			return nil, fmt.Errorf("invalid filter: %s", arg)
+		}
+		if m.Label == "" {
+			return nil, fmt.Errorf("invalid filter: %s", arg)
+		}
+		matchers = append(matchers, m)
+	}
+	if !equal {
+		return nil, fmt.Errorf("FILTER requires at least one label=value matcher")
+	}
+	return matchers, nil
+}
+
+// Match returns the keys of the series satisfying every matcher, in
+// ascending order. Candidates are taken from the index entry of the
+// rarest label=value matcher and then checked against the others.
+func (s *TimeSeriesStore) Match(matchers []LabelMatcher) []string {
+	s.indexMu.RLock()
+	var candidates map[string]struct{}
+	for _, m := range matchers {
+		if !m.Equal {
+			continue
+		}
+		keys := s.index[m.Label][m.Value]
+		if candidates == nil || len(keys) < len(candidates) {
+			candidates = keys
+		}
+	}
+	keys := store.SortedKeys(candidates)
+	s.indexMu.RUnlock()
+
+	matched := keys[:0]
+	for _, key := range keys {
+		series, exists := s.series.Get(key)
+		if !exists {
+			continue
+		}
+		series.mu.RLock()
+		ok := true
+		for _, m := range matchers {
+			if !m.matches(series.labels) {
+				ok = false
+				break
+			}
+		}
+		series.mu.RUnlock()
+		if ok {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}
+
+// seriesSnapshot is the saved form of a series
+type seriesSnapshot struct {
+	Points    []TimeSeriesPoint `json:"points"`
+	Retention time.Duration     `json:"retention,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// UnmarshalJSON also accepts a bare array of points, the format saved
+// before series had a retention window
+func (s *seriesSnapshot) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return json.Unmarshal(data, &s.Points)
+	}
+	type plain seriesSnapshot
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// Save writes all series to w as JSON
+func (s *TimeSeriesStore) Save(w io.Writer) error {
+	snapshot := make(map[string]seriesSnapshot)
+	s.series.Range(func(key string, series *TimeSeries) bool {
+		series.mu.RLock()
+		snapshot[key] = seriesSnapshot{
+			Points:    append([]TimeSeriesPoint(nil), series.points...),
+			Retention: series.retention,
+			Labels:    maps.Clone(series.labels),
+		}
+		series.mu.RUnlock()
+		return true
+	})
+	return persist.JSON(&snapshot).Save(w)
+}
+
+// Load replaces all series with the JSON snapshot read from r
+func (s *TimeSeriesStore) Load(r io.Reader) error {
+	var snapshot map[string]seriesSnapshot
+	if err := persist.JSON(&snapshot).Load(r); err != nil {
+		return err
 	}
+
+	s.series.Clear()
+	s.indexMu.Lock()
+	s.index = make(map[string]map[string]map[string]struct{})
+	s.indexMu.Unlock()
+
+	for key, saved := range snapshot {
+		series := newTimeSeries(saved.Points, saved.Retention)
+		series.mu.Lock()
+		s.SetLabels(key, series, saved.Labels)
+		series.mu.Unlock()
+		s.series.Set(key, series)
+	}
+	return nil
 }
 
 func main() {
 	// Create time series store
-	store := NewTimeSeriesStore()
+	db := NewTimeSeriesStore()
 
 	// Create extension
 	ext := command.NewExtension("time-series")
@@ -52,96 +529,182 @@ func main() {
 	// TS.ADD command
 	addCmd := command.New("TS.ADD")
 	addCmd.Description = "Add a data point to a time series"
+	addCmd.Flags = command.FlagWrite
+	addCmd.MinArgs = 3
+	addCmd.ArgSpec = []command.ArgType{command.ArgKey, command.ArgString, command.ArgFloat}
+	addCmd.ArgNames = []string{"key", "timestamp|*", "value", "[LABELS label value ...]"}
 	addCmd.Handler = func(ctx *command.Context) error {
-		if len(ctx.Args) != 4 {
-			return fmt.Errorf("usage: TS.ADD <key> <timestamp> <value>")
-		}
-
 		key := ctx.Args[1]
-		timestamp, err := time.Parse(time.RFC3339, ctx.Args[2])
+		timestamp, err := parseTimestamp(ctx.Args[2], time.Now())
 		if err != nil {
-			return fmt.Errorf("invalid timestamp format, use RFC3339")
+			return errInvalidTimestamp
 		}
+		value := ctx.Float(3)
 
-		value, err := strconv.ParseFloat(ctx.Args[3], 64)
-		if err != nil {
-			return fmt.Errorf("invalid value: %v", err)
+		var labels map[string]string
+		if len(ctx.Args) > 4 {
+			if labels, err = parseLabels(ctx.Args[4:]); err != nil {
+				return err
+			}
 		}
 
-		store.mu.Lock()
-		if _, exists := store.series[key]; !exists {
-			store.series[key] = &TimeSeries{
-				points: make([]TimeSeriesPoint, 0),
-			}
+		if err := db.Add(key, TimeSeriesPoint{Timestamp: timestamp, Value: value}, labels); err != nil {
+			return err
 		}
-		store.mu.Unlock()
+		resolveNow(ctx.Args, 2, timestamp)
 
-		series := store.series[key]
-		series.mu.Lock()
-		series.points = append(series.points, TimeSeriesPoint{
-			Timestamp: timestamp,
-			Value:     value,
-		})
-		series.mu.Unlock()
+		// The timestamp added, a millisecond epoch unless RFC3339 is chosen
+		return ctx.ReplyValue(db.Timestamps.format(timestamp))
+	}
 
-		return ctx.Reply("OK")
+	// TS.MADD command
+	maddCmd := command.New("TS.MADD")
+	maddCmd.Description = "Add data points to several time series at once"
+	maddCmd.Flags = command.FlagWrite
+	maddCmd.MinArgs = 3
+	maddCmd.Keys = &command.KeySpec{First: 1, Last: -3, Step: 3}
+	maddCmd.ArgNames = []string{"key", "timestamp|*", "value", "[key timestamp|* value ...]"}
+	maddCmd.Handler = func(ctx *command.Context) error {
+		args := ctx.Args[1:]
+		if len(args)%3 != 0 {
+			return maddCmd.ArityError(ctx.Args[0])
+		}
+
+		// Each point succeeds or fails on its own
+		if err := ctx.ReplyArray(len(args) / 3); err != nil {
+			return err
+		}
+		for i := 0; i < len(args); i += 3 {
+			point, err := parsePoint(args[i], args[i+1], args[i+2])
+			if err == nil {
+				err = db.Add(args[i], point, nil)
+			}
+			if err == nil {
+				resolveNow(args, i+1, point.Timestamp)
+			}
+
+			if err != nil {
+				if err := ctx.ReplyError(err); err != nil {
+					return err
+				}
+			} else if err := ctx.ReplyValue(db.Timestamps.format(point.Timestamp)); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
 	// TS.RANGE command
 	rangeCmd := command.New("TS.RANGE")
 	rangeCmd.Description = "Get time series data points within a time range"
+	rangeCmd.MinArgs = 3
+	rangeCmd.MaxArgs = 7
+	rangeCmd.Keys = &command.KeySpec{First: 1, Last: 1, Step: 1}
+	rangeCmd.ArgNames = []string{"key", "[(]start", "[(]end", "[AGGREGATION avg|min|max|sum bucket_ms [EMPTY]]"}
 	rangeCmd.Handler = func(ctx *command.Context) error {
-		if len(ctx.Args) != 4 {
-			return fmt.Errorf("usage: TS.RANGE <key> <start_timestamp> <end_timestamp>")
+		key := ctx.Args[1]
+		start, err := parseBound(ctx.Args[2])
+		if err != nil {
+			return fmt.Errorf("invalid start timestamp, use milliseconds since the epoch, -, + or RFC3339")
 		}
 
-		key := ctx.Args[1]
-		start, err := time.Parse(time.RFC3339, ctx.Args[2])
+		end, err := parseBound(ctx.Args[3])
 		if err != nil {
-			return fmt.Errorf("invalid start timestamp format, use RFC3339")
+			return fmt.Errorf("invalid end timestamp, use milliseconds since the epoch, -, + or RFC3339")
 		}
 
-		end, err := time.Parse(time.RFC3339, ctx.Args[3])
+		agg, err := parseAggregation(ctx.Args[4:])
 		if err != nil {
-			return fmt.Errorf("invalid end timestamp format, use RFC3339")
+			return err
 		}
 
-		store.mu.RLock()
-		series, exists := store.series[key]
-		store.mu.RUnlock()
+		series, exists := db.Get(key)
 
 		if !exists {
-			return fmt.Errorf("time series not found: %s", key)
+			return ctx.ReplyNotFound()
 		}
 
 		series.mu.RLock()
-		var results []string
-		for _, point := range series.points {
-			if point.Timestamp.After(start) && point.Timestamp.Before(end) {
-				results = append(results, fmt.Sprintf("%s %.2f", point.Timestamp.Format(time.RFC3339), point.Value))
+		defer series.mu.RUnlock()
+
+		points := series.between(start, end)
+		if agg != nil {
+			return db.replyPoints(ctx, agg.Apply(points))
+		}
+
+		// Stream points so RESP3 clients can handle them as they arrive
+		if err := ctx.BeginStream(); err != nil {
+			return err
+		}
+		for _, point := range points {
+			if err := ctx.ReplyValue(db.formatPoint(point)); err != nil {
+				return err
 			}
 		}
-		series.mu.RUnlock()
+		return ctx.EndStream()
+	}
+
+	// TS.MRANGE command
+	mrangeCmd := command.New("TS.MRANGE")
+	mrangeCmd.Description = "Get data points within a time range from every series matching a label filter"
+	mrangeCmd.Flags = command.FlagReadOnly
+	mrangeCmd.MinArgs = 4
+	mrangeCmd.ArgNames = []string{"[(]start", "[(]end", "FILTER", "label=value", "[label[!]=value ...]"}
+	mrangeCmd.Handler = func(ctx *command.Context) error {
+		start, err := parseBound(ctx.Args[1])
+		if err != nil {
+			return fmt.Errorf("invalid start timestamp, use milliseconds since the epoch, -, + or RFC3339")
+		}
+
+		end, err := parseBound(ctx.Args[2])
+		if err != nil {
+			return fmt.Errorf("invalid end timestamp, use milliseconds since the epoch, -, + or RFC3339")
+		}
+
+		if !strings.EqualFold(ctx.Args[3], "FILTER") {
+			return fmt.Errorf("usage: %s", mrangeCmd.Usage(ctx.Args[0]))
+		}
+		matchers, err := parseFilter(ctx.Args[4:])
+		if err != nil {
+			return err
+		}
+
+		// A matched series can be deleted before it is read, so the
+		// entries are collected before the reply's length is known
+		var entries []interface{}
+		for _, key := range db.Match(matchers) {
+			series, exists := db.Get(key)
+			if !exists {
+				continue
+			}
+			series.mu.RLock()
+			points := series.between(start, end)
+			entries = append(entries, []interface{}{key, maps.Clone(series.labels), db.formatPoints(points)})
+			series.mu.RUnlock()
+		}
 
-		return ctx.Reply(fmt.Sprintf("[%s]", strings.Join(results, ", ")))
+		reply := ctx.BeginArray(len(entries))
+		for _, entry := range entries {
+			if err := reply.Add(entry); err != nil {
+				return err
+			}
+		}
+		return reply.Close()
 	}
 
 	// TS.STATS command
 	statsCmd := command.New("TS.STATS")
 	statsCmd.Description = "Get statistics for a time series"
+	statsCmd.MinArgs = 1
+	statsCmd.MaxArgs = 1
+	statsCmd.Keys = &command.KeySpec{First: 1, Last: 1, Step: 1}
 	statsCmd.Handler = func(ctx *command.Context) error {
-		if len(ctx.Args) != 2 {
-			return fmt.Errorf("usage: TS.STATS <key>")
-		}
-
 		key := ctx.Args[1]
 
-		store.mu.RLock()
-		series, exists := store.series[key]
-		store.mu.RUnlock()
+		series, exists := db.Get(key)
 
 		if !exists {
-			return fmt.Errorf("time series not found: %s", key)
+			return ctx.ReplyNotFound()
 		}
 
 		series.mu.RLock()
@@ -178,131 +741,277 @@ func main() {
 		return ctx.Reply(stats)
 	}
 
-	// Register commands
-	ext.AddCommand(addCmd)
-	ext.AddCommand(rangeCmd)
-	ext.AddCommand(statsCmd)
+	// TS.RETENTION command
+	retentionCmd := command.New("TS.RETENTION")
+	retentionCmd.Description = "Set how long a time series keeps its points"
+	retentionCmd.Flags = command.FlagWrite
+	retentionCmd.MinArgs = 2
+	retentionCmd.MaxArgs = 2
+	retentionCmd.ArgSpec = []command.ArgType{command.ArgKey, command.ArgInt}
+	retentionCmd.ArgNames = []string{"key", "seconds"}
+	retentionCmd.Handler = func(ctx *command.Context) error {
+		seconds := ctx.Int(2)
+		if seconds < 0 || seconds > math.MaxInt64/int64(time.Second) {
+			return fmt.Errorf("retention must be a non-negative number of seconds")
+		}
 
-	// Start TCP server
-	listener, err := net.Listen("tcp", ":6380")
-	if err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		series, exists := db.Get(ctx.Args[1])
+		if !exists {
+			return ctx.ReplyInt(0)
+		}
+
+		series.mu.Lock()
+		series.retention = time.Duration(seconds) * time.Second
+		series.trim(time.Now())
+		series.mu.Unlock()
+
+		return ctx.ReplyInt(1)
 	}
-	defer listener.Close()
 
-	log.Printf("Time series extension listening on :6380")
+	// TS.INFO command
+	infoCmd := command.New("TS.INFO")
+	infoCmd.Description = "Get the size, retention and labels of a time series"
+	infoCmd.Flags = command.FlagReadOnly
+	infoCmd.MinArgs = 1
+	infoCmd.MaxArgs = 1
+	infoCmd.ArgSpec = []command.ArgType{command.ArgKey}
+	infoCmd.Handler = func(ctx *command.Context) error {
+		series, exists := db.Get(ctx.Args[1])
+		if !exists {
+			return ctx.ReplyNotFound()
+		}
+
+		series.mu.RLock()
+		defer series.mu.RUnlock()
+
+		if err := ctx.ReplyMap(3); err != nil {
+			return err
+		}
+		ctx.Reply("points")
+		ctx.ReplyInt(int64(len(series.points)))
+		ctx.Reply("retention")
+		ctx.ReplyInt(int64(series.retention / time.Second))
+		ctx.Reply("labels")
+		return ctx.ReplyValue(maps.Clone(series.labels))
+	}
+
+	// TS.MEMUSAGE command
+	memCmd := command.MemUsage("TS.MEMUSAGE", func(key string) (interface{}, bool) {
+		return db.Get(key)
+	})
+
+	// Register commands
+	if err := ext.AddCommands(addCmd, maddCmd, rangeCmd, mrangeCmd, statsCmd, retentionCmd, infoCmd, memCmd); err != nil {
+		log.Fatalf("Failed to register commands: %v", err)
+	}
+
+	// Drop points outside their retention window in the background
+	ext.OnStart(db.Start)
+	ext.OnStop(db.Stop)
+
+	// Start server
+	srv := server.New(ext)
+	srv.EnableSnapshots("time-series.json", db, 5*time.Minute)
+	srv.RegisterKeyspace("timeseries", db)
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	stopped := make(chan struct{})
 	go func() {
 		<-sigChan
 		log.Println("Shutting down...")
-		listener.Close()
-	}()
-
-	// Accept connections
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			if opErr, ok := err.(*net.OpError); ok && opErr.Err.Error() == "use of closed network connection" {
-				break
-			}
-			log.Printf("Failed to accept connection: %v", err)
-			continue
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Printf("Shutdown error: %v", err)
 		}
+		close(stopped)
+	}()
 
-		go handleConnection(conn, ext)
+	log.Printf("Time series extension listening on :6380")
+	if err := srv.ListenAndServe(":6380"); err != server.ErrServerClosed {
+		log.Fatalf("Failed to start server: %v", err)
 	}
+	<-stopped
 }
 
-func handleConnection(conn net.Conn, ext *command.Extension) {
-	defer conn.Close()
-
-	reader := resp.NewReader(conn)
-	writer := resp.NewWriter(conn)
-	rConn := &redisConn{writer: writer}
+// Aggregation groups points into fixed-width time buckets aligned to the
+// Unix epoch and reduces each bucket to a single value
+type Aggregation struct {
+	Reduce func(values []float64) float64
+	Bucket int64 // bucket width in milliseconds
+	Empty  bool  // report buckets without points as zero instead of skipping them
+}
 
-	for {
-		// Read command
-		obj, err := reader.ReadObject()
-		if err != nil {
-			if err != io.EOF {
-				log.Printf("Error reading command: %v", err)
-			}
-			return
+// reducers maps the AGGREGATION types accepted by TS.RANGE to their functions
+var reducers = map[string]func(values []float64) float64{
+	"avg": func(values []float64) float64 {
+		var sum float64
+		for _, v := range values {
+			sum += v
 		}
-
-		// Parse command array
-		cmdArray, ok := obj.([]interface{})
-		if !ok {
-			rConn.WriteError(fmt.Errorf("invalid command format"))
-			continue
+		return sum / float64(len(values))
+	},
+	"min": func(values []float64) float64 {
+		result := values[0]
+		for _, v := range values[1:] {
+			result = math.Min(result, v)
 		}
-
-		if len(cmdArray) == 0 {
-			rConn.WriteError(fmt.Errorf("empty command"))
-			continue
+		return result
+	},
+	"max": func(values []float64) float64 {
+		result := values[0]
+		for _, v := range values[1:] {
+			result = math.Max(result, v)
 		}
-
-		// Get command name
-		cmdName, ok := cmdArray[0].(string)
-		if !ok {
-			rConn.WriteError(fmt.Errorf("invalid command name"))
-			continue
+		return result
+	},
+	"sum": func(values []float64) float64 {
+		var sum float64
+		for _, v := range values {
+			sum += v
 		}
+		return sum
+	},
+}
 
-		// Get command
-		cmd, err := ext.GetCommand(cmdName)
-		if err != nil {
-			rConn.WriteError(err)
-			continue
-		}
+// rangeOptions are the options accepted by TS.RANGE after the time range
+var rangeOptions = []command.OptionSpec{
+	{
+		Name:    "AGGREGATION",
+		Values:  []command.ArgType{command.ArgString, command.ArgInt},
+		Allowed: []string{"avg", "min", "max", "sum"},
+	},
+	{Name: "EMPTY"},
+}
 
-		// Convert arguments to strings
-		args := make([]string, len(cmdArray))
-		for i, arg := range cmdArray {
-			args[i] = fmt.Sprint(arg)
+// parseAggregation parses "[AGGREGATION <type> <bucket_ms> [EMPTY]]",
+// returning nil without an AGGREGATION option
+func parseAggregation(args []string) (*Aggregation, error) {
+	opts, err := command.ParseOptions(args, rangeOptions)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Has("AGGREGATION") {
+		if opts.Has("EMPTY") {
+			return nil, fmt.Errorf("EMPTY requires AGGREGATION")
 		}
+		return nil, nil
+	}
 
-		// Create context
-		ctx := &command.Context{
-			Args: args,
-			Conn: rConn,
-		}
+	bucket := opts.Int("AGGREGATION", 1)
+	if bucket <= 0 {
+		return nil, fmt.Errorf("bucket size must be a positive number of milliseconds")
+	}
+
+	return &Aggregation{
+		Reduce: reducers[opts.String("AGGREGATION", 0)],
+		Bucket: bucket,
+		Empty:  opts.Has("EMPTY"),
+	}, nil
+}
+
+// Apply returns one point per bucket, stamped with the bucket's start time,
+// in chronological order. Empty buckets between the first and last
+// non-empty bucket are included with a zero value when Empty is set.
+func (a *Aggregation) Apply(points []TimeSeriesPoint) []TimeSeriesPoint {
+	buckets := make(map[int64][]float64)
+	for _, point := range points {
+		ts := point.Timestamp.UnixMilli()
+		// Floor towards negative infinity so pre-epoch buckets stay aligned
+		start := ts - ((ts%a.Bucket)+a.Bucket)%a.Bucket
+		buckets[start] = append(buckets[start], point.Value)
+	}
+
+	starts := store.SortedKeys(buckets)
 
-		// Execute command
-		if err := cmd.Handler(ctx); err != nil {
-			rConn.WriteError(err)
+	var result []TimeSeriesPoint
+	for i, start := range starts {
+		if a.Empty && i > 0 {
+			for gap := starts[i-1] + a.Bucket; gap < start; gap += a.Bucket {
+				result = append(result, TimeSeriesPoint{Timestamp: time.UnixMilli(gap).UTC()})
+			}
 		}
+		result = append(result, TimeSeriesPoint{
+			Timestamp: time.UnixMilli(start).UTC(),
+			Value:     a.Reduce(buckets[start]),
+		})
 	}
+	return result
 }
 
-type redisConn struct {
-	writer *resp.Writer
+// formatPoint encodes a point as a [timestamp, value] reply pair
+func (s *TimeSeriesStore) formatPoint(point TimeSeriesPoint) []interface{} {
+	return []interface{}{
+		s.Timestamps.format(point.Timestamp),
+		strconv.FormatFloat(point.Value, 'f', 2, 64),
+	}
 }
 
-func (c *redisConn) WriteString(s string) error {
-	return c.writer.WriteBulkString(s)
+// formatPoints encodes points as an array of [timestamp, value] pairs
+func (s *TimeSeriesStore) formatPoints(points []TimeSeriesPoint) []interface{} {
+	pairs := make([]interface{}, len(points))
+	for i, point := range points {
+		pairs[i] = s.formatPoint(point)
+	}
+	return pairs
 }
 
-func (c *redisConn) WriteInt(i int64) error {
-	return c.writer.WriteInteger(i)
+// resolveNow replaces a "*" timestamp in args[i] with the time it stood
+// for, so that the AOF replays the point at that time rather than at the
+// time of the replay
+func resolveNow(args []string, i int, timestamp time.Time) {
+	if args[i] == "*" {
+		args[i] = strconv.FormatInt(timestamp.UnixMilli(), 10)
+	}
 }
 
-func (c *redisConn) WriteArray(length int) error {
-	return c.writer.WriteArray(length)
+// parsePoint parses the key, timestamp and value of one TS.MADD point the
+// way TS.ADD checks its arguments
+func parsePoint(key, timestamp, value string) (TimeSeriesPoint, error) {
+	if key == "" {
+		return TimeSeriesPoint{}, fmt.Errorf("key cannot be empty")
+	}
+	t, err := parseTimestamp(timestamp, time.Now())
+	if err != nil {
+		return TimeSeriesPoint{}, errInvalidTimestamp
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return TimeSeriesPoint{}, fmt.Errorf("invalid value: must be a finite number")
+	}
+	return TimeSeriesPoint{Timestamp: t, Value: v}, nil
 }
 
-func (c *redisConn) WriteNull() error {
-	return c.writer.WriteBulkString("")
-}
+// parseLabels parses "LABELS <name> <value> ..." into a label map
+func parseLabels(args []string) (map[string]string, error) {
+	if !strings.EqualFold(args[0], "LABELS") {
+		return nil, fmt.Errorf("unknown option: %s", args[0])
+	}
+	pairs := args[1:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("LABELS requires name value pairs")
+	}
 
-func (c *redisConn) WriteError(err error) error {
-	return c.writer.WriteError(err)
+	labels := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i] == "" {
+			return nil, fmt.Errorf("label names cannot be empty")
+		}
+		labels[pairs[i]] = pairs[i+1]
+	}
+	return labels, nil
 }
 
-func (c *redisConn) Flush() error {
+// replyPoints sends points as an array of [timestamp, value] pairs
+func (s *TimeSeriesStore) replyPoints(ctx *command.Context, points []TimeSeriesPoint) error {
+	if err := ctx.ReplyArray(len(points)); err != nil {
+		return err
+	}
+	for _, point := range points {
+		if err := ctx.ReplyValue(s.formatPoint(point)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
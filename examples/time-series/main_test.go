@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/store"
+)
+
+// TestAddDelete races Add against Delete of the same series and checks
+// after each round that the label index agrees with the stored series
+func TestAddDelete(t *testing.T) {
+	db := NewTimeSeriesStore()
+	labels := map[string]string{"sensor": "temp"}
+	matchers := []LabelMatcher{{Label: "sensor", Value: "temp", Equal: true}}
+
+	for ms := int64(0); ms < 2000; ms++ {
+		point := TimeSeriesPoint{Timestamp: time.UnixMilli(ms), Value: 1}
+		var wg sync.WaitGroup
+		wg.Add(3)
+		for i := 0; i < 2; i++ {
+			go func() {
+				defer wg.Done()
+				if err := db.Add("series", point, labels); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		go func() {
+			defer wg.Done()
+			db.Delete("series")
+		}()
+		wg.Wait()
+
+		_, exists := db.Get("series")
+		if keys := db.Match(matchers); exists != (len(keys) == 1) {
+			t.Fatalf("round %d: series stored = %v but index matches %v", ms, exists, keys)
+		}
+	}
+}
+
+// seriesOf builds a series by adding a point at each millisecond timestamp
+// in order, with the timestamp as its value
+func seriesOf(t *testing.T, policy OutOfOrderPolicy, ms ...int64) *TimeSeries {
+	t.Helper()
+
+	ts := newTimeSeries(nil, 0)
+	for _, m := range ms {
+		if err := ts.add(TimeSeriesPoint{Timestamp: time.UnixMilli(m), Value: float64(m)}, policy); err != nil {
+			t.Fatalf("add %d: %v", m, err)
+		}
+	}
+	return ts
+}
+
+// values returns the values of points
+func values(points []TimeSeriesPoint) []float64 {
+	vs := make([]float64, len(points))
+	for i, p := range points {
+		vs[i] = p.Value
+	}
+	return vs
+}
+
+// bound returns an inclusive Bound at ms
+func bound(ms int64) Bound {
+	return Bound{Time: time.UnixMilli(ms)}
+}
+
+func TestAddOutOfOrder(t *testing.T) {
+	ts := seriesOf(t, InsertOutOfOrder, 50, 10, 40, 20, 30, 10, 60)
+
+	want := []float64{10, 10, 20, 30, 40, 50, 60}
+	if got := values(ts.points); !slices.Equal(got, want) {
+		t.Errorf("points = %v, want %v", got, want)
+	}
+	if !slices.IsSortedFunc(ts.points, func(a, b TimeSeriesPoint) int { return a.Timestamp.Compare(b.Timestamp) }) {
+		t.Errorf("points are not sorted: %v", ts.points)
+	}
+
+	// Points with equal timestamps stay in the order they were added
+	ts.add(TimeSeriesPoint{Timestamp: time.UnixMilli(20), Value: -1}, InsertOutOfOrder)
+	if got := values(ts.between(bound(20), bound(20))); !slices.Equal(got, []float64{20, -1}) {
+		t.Errorf("points at 20 = %v, want [20 -1]", got)
+	}
+}
+
+func TestAddRejectOutOfOrder(t *testing.T) {
+	ts := seriesOf(t, RejectOutOfOrder, 10, 20, 20, 30)
+
+	if err := ts.add(TimeSeriesPoint{Timestamp: time.UnixMilli(25)}, RejectOutOfOrder); !errors.Is(err, errOutOfOrder) {
+		t.Errorf("add 25 after 30 error = %v, want errOutOfOrder", err)
+	}
+	if got := values(ts.points); !slices.Equal(got, []float64{10, 20, 20, 30}) {
+		t.Errorf("points = %v, want [10 20 20 30]", got)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	ts := seriesOf(t, InsertOutOfOrder, 40, 0, 30, 10, 20)
+
+	tests := []struct {
+		name       string
+		start, end Bound
+		want       []float64
+	}{
+		{"all", bound(math.MinInt64), bound(math.MaxInt64), []float64{0, 10, 20, 30, 40}},
+		{"inside", bound(5), bound(25), []float64{10, 20}},
+		{"overlapping start", bound(-100), bound(15), []float64{0, 10}},
+		{"overlapping end", bound(35), bound(100), []float64{40}},
+		{"between points", bound(11), bound(19), []float64{}},
+		{"before every point", bound(-20), bound(-10), []float64{}},
+		{"after every point", bound(50), bound(60), []float64{}},
+		{"reversed", bound(30), bound(10), []float64{}},
+	}
+	for _, tt := range tests {
+		if got := values(ts.between(tt.start, tt.end)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: between = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got := values(newTimeSeries(nil, 0).between(bound(0), bound(100))); len(got) != 0 {
+		t.Errorf("between on an empty series = %v, want none", got)
+	}
+}
+
+func TestBetweenBounds(t *testing.T) {
+	ts := seriesOf(t, InsertOutOfOrder, 10, 20, 20, 30)
+	exclusive := func(ms int64) Bound { return Bound{Time: time.UnixMilli(ms), Exclusive: true} }
+
+	tests := []struct {
+		name       string
+		start, end Bound
+		want       []float64
+	}{
+		{"points on both bounds", bound(10), bound(30), []float64{10, 20, 20, 30}},
+		{"point on start", bound(20), bound(25), []float64{20, 20}},
+		{"point on end", bound(15), bound(20), []float64{20, 20}},
+		{"exclusive start", exclusive(10), bound(30), []float64{20, 20, 30}},
+		{"exclusive end", bound(10), exclusive(30), []float64{10, 20, 20}},
+		{"exclusive both", exclusive(10), exclusive(30), []float64{20, 20}},
+		{"start equals end", bound(20), bound(20), []float64{20, 20}},
+		{"start equals end off a point", bound(25), bound(25), []float64{}},
+		{"start equals end, exclusive start", exclusive(20), bound(20), []float64{}},
+		{"start equals end, exclusive end", bound(20), exclusive(20), []float64{}},
+	}
+	for _, tt := range tests {
+		if got := values(ts.between(tt.start, tt.end)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: between = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseBound(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want Bound
+	}{
+		{"1000", Bound{Time: time.UnixMilli(1000)}},
+		{"(1000", Bound{Time: time.UnixMilli(1000), Exclusive: true}},
+		{"-", Bound{Time: time.UnixMilli(math.MinInt64)}},
+		{"(+", Bound{Time: time.UnixMilli(math.MaxInt64), Exclusive: true}},
+		{"2024-01-02T03:04:05Z", Bound{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}},
+	}
+	for _, tt := range tests {
+		got, err := parseBound(tt.arg)
+		if err != nil || !got.Time.Equal(tt.want.Time) || got.Exclusive != tt.want.Exclusive {
+			t.Errorf("parseBound(%q) = %v, %v, want %v", tt.arg, got, err, tt.want)
+		}
+	}
+	for _, arg := range []string{"", "(", "((1000", "soon"} {
+		if _, err := parseBound(arg); err == nil {
+			t.Errorf("parseBound(%q) succeeded", arg)
+		}
+	}
+}
+
+func TestAggregation(t *testing.T) {
+	agg, err := parseAggregation([]string{"AGGREGATION", "sum", "250", "EMPTY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := seriesOf(t, InsertOutOfOrder, -100, 0, 100, 249, 250, 900)
+
+	var got []int64
+	for _, p := range agg.Apply(ts.points) {
+		got = append(got, p.Timestamp.UnixMilli())
+	}
+	if want := []int64{-250, 0, 250, 500, 750}; !slices.Equal(got, want) {
+		t.Errorf("bucket starts = %v, want %v", got, want)
+	}
+	if got, want := values(agg.Apply(ts.points)), []float64{-100, 349, 250, 0, 900}; !slices.Equal(got, want) {
+		t.Errorf("bucket sums = %v, want %v", got, want)
+	}
+
+	for _, args := range [][]string{
+		{"AGGREGATION", "avg", "0"},
+		{"AGGREGATION", "median", "1000"},
+		{"EMPTY"},
+	} {
+		if _, err := parseAggregation(args); err == nil {
+			t.Errorf("parseAggregation(%q) succeeded", args)
+		}
+	}
+}
+
+func TestResolveNow(t *testing.T) {
+	at := time.UnixMilli(1700000000123)
+	args := []string{"TS.MADD", "a", "*", "1", "b", "1000", "2"}
+	resolveNow(args, 2, at)
+	resolveNow(args, 5, time.UnixMilli(1000))
+
+	want := []string{"TS.MADD", "a", "1700000000123", "1", "b", "1000", "2"}
+	if !slices.Equal(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	now := time.UnixMilli(100_000)
+	points := make([]TimeSeriesPoint, 100)
+	for i := range points {
+		points[i] = TimeSeriesPoint{Timestamp: now.Add(time.Duration(i-99) * time.Second), Value: float64(i)}
+	}
+	ts := newTimeSeries(points, 10*time.Second)
+
+	ts.trim(now)
+	if len(ts.points) != 11 || ts.points[0].Value != 89 {
+		t.Fatalf("trim kept %d points from %v, want 11 from 89", len(ts.points), ts.points[0].Value)
+	}
+	if cap(ts.points) != len(ts.points) {
+		t.Errorf("trim kept a backing array of %d points for %d", cap(ts.points), len(ts.points))
+	}
+
+	ts.trim(now.Add(time.Second))
+	if len(ts.points) != 10 || ts.points[0].Value != 90 {
+		t.Errorf("trim kept %d points from %v, want 10 from 90", len(ts.points), ts.points[0].Value)
+	}
+}
+
+// BenchmarkTSAdd measures TS.ADD throughput with the series map in one
+// shard and in 16. Each goroutine writes to its own series, so only the
+// map's locks are shared.
+func BenchmarkTSAdd(b *testing.B) {
+	for _, shards := range []int{1, 16} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			db := NewTimeSeriesStore()
+			db.series = store.NewSharded[*TimeSeries](shards)
+
+			var goroutines atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				key := fmt.Sprintf("series:%d", goroutines.Add(1))
+				for ms := int64(0); pb.Next(); ms++ {
+					point := TimeSeriesPoint{Timestamp: time.UnixMilli(ms), Value: 1}
+					if err := db.Add(key, point, nil); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Package client is a minimal RESP client for talking to an upstream
+// redis, or another GoLuxis server, from inside a handler.
+package client
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+)
+
+const (
+	// DefaultDialTimeout is used when Options.DialTimeout is zero
+	DefaultDialTimeout = 5 * time.Second
+	// DefaultReadTimeout is used when Options.ReadTimeout is zero
+	DefaultReadTimeout = 3 * time.Second
+	// DefaultWriteTimeout is used when Options.WriteTimeout is zero
+	DefaultWriteTimeout = 3 * time.Second
+)
+
+// ErrBroken is returned by Do on a connection that failed earlier
+var ErrBroken = errors.New("connection broken by an earlier error")
+
+// ReplyError is an error reply sent by the server, such as
+// "ERR unknown command". The connection stays usable after one.
+type ReplyError string
+
+func (e ReplyError) Error() string {
+	return string(e)
+}
+
+// Options configures a connection. Zero durations take their defaults,
+// and a negative ReadTimeout or WriteTimeout means no limit.
+type Options struct {
+	// DialTimeout bounds how long dialing waits for a connection
+	DialTimeout time.Duration
+	// ReadTimeout bounds how long Do waits for a reply, so that a hung
+	// server fails the call instead of blocking it
+	ReadTimeout time.Duration
+	// WriteTimeout bounds how long Do takes to send a command
+	WriteTimeout time.Duration
+}
+
+// timeout returns d, def if d is zero, or zero for no limit if d is negative
+func timeout(d, def time.Duration) time.Duration {
+	switch {
+	case d == 0:
+		return def
+	case d < 0:
+		return 0
+	}
+	return d
+}
+
+// Conn is a connection to a server speaking RESP2. It is not safe for
+// concurrent use; share connections through a Pool instead.
+type Conn struct {
+	netConn      net.Conn
+	reader       *resp.Reader
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	broken       bool
+}
+
+// Dial connects to the server at addr with the default Options
+func Dial(addr string) (*Conn, error) {
+	return DialOptions(addr, Options{})
+}
+
+// DialOptions connects to the server at addr. Compressed replies, sent
+// once the connection negotiates them with HELLO 3 COMPRESS, are
+// decompressed.
+func DialOptions(addr string, opts Options) (*Conn, error) {
+	netConn, err := net.DialTimeout("tcp", addr, timeout(opts.DialTimeout, DefaultDialTimeout))
+	if err != nil {
+		return nil, err
+	}
+	reader := resp.NewReader(netConn)
+	reader.SetDecompress(true)
+	return &Conn{
+		netConn:      netConn,
+		reader:       reader,
+		readTimeout:  timeout(opts.ReadTimeout, DefaultReadTimeout),
+		writeTimeout: timeout(opts.WriteTimeout, DefaultWriteTimeout),
+	}, nil
+}
+
+// Do sends a command and returns its reply. An error reply is returned as
+// a ReplyError. Any other error, including a timeout, leaves the
+// connection broken: later calls fail with ErrBroken, and a Pool closes it
+// instead of reusing it.
+func (c *Conn) Do(args ...string) (*resp.Reply, error) {
+	if c.broken {
+		return nil, ErrBroken
+	}
+
+	data, err := resp.Marshal(args, resp.RESP2)
+	if err != nil {
+		return nil, err
+	}
+	if c.writeTimeout > 0 {
+		c.netConn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	if _, err := c.netConn.Write(data); err != nil {
+		c.broken = true
+		return nil, err
+	}
+
+	if c.readTimeout > 0 {
+		c.netConn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	reply, err := c.reader.ReadReply()
+	if err != nil {
+		c.broken = true
+		return nil, err
+	}
+	if err := reply.Err(); err != nil {
+		return nil, ReplyError(err.Error())
+	}
+	return reply, nil
+}
+
+// Broken reports whether an earlier call failed with an I/O or protocol
+// error
+func (c *Conn) Broken() bool {
+	return c.broken
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	c.broken = true
+	return c.netConn.Close()
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer accepts connections on a local port and answers each command
+// with reply, or never answers if reply is empty. It returns the address
+// to dial.
+func fakeServer(t *testing.T, reply string) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	t.Cleanup(func() {
+		l.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	})
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+			go func() {
+				r := bufio.NewReader(c)
+				for {
+					// Commands are arrays of bulk strings; one reply is
+					// sent per array header
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line[0] == '*' && reply != "" {
+						c.Write([]byte(reply))
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestDoReplies(t *testing.T) {
+	tests := []struct {
+		reply string
+		check func(t *testing.T, c *Conn)
+	}{
+		{"$-1\r\n", func(t *testing.T, c *Conn) {
+			reply, err := c.Do("GET", "missing")
+			if err != nil || !reply.IsNil() {
+				t.Errorf("Do = %v, %v, want a nil reply", reply, err)
+			}
+		}},
+		{"$0\r\n\r\n", func(t *testing.T, c *Conn) {
+			reply, err := c.Do("GET", "empty")
+			if err != nil || reply.IsNil() {
+				t.Errorf("Do = %v, %v, want an empty string", reply, err)
+			}
+		}},
+		{"-ERR unknown command\r\n", func(t *testing.T, c *Conn) {
+			_, err := c.Do("NOPE")
+			var replyErr ReplyError
+			if !errors.As(err, &replyErr) || replyErr != "ERR unknown command" {
+				t.Errorf("Do error = %v, want ReplyError", err)
+			}
+			if c.Broken() {
+				t.Error("connection broken by an error reply")
+			}
+		}},
+	}
+	for _, tt := range tests {
+		c, err := Dial(fakeServer(t, tt.reply))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.check(t, c)
+		c.Close()
+	}
+}
+
+func TestDoReadTimeout(t *testing.T) {
+	c, err := DialOptions(fakeServer(t, ""), Options{ReadTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+	_, err = c.Do("PING")
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Do on a hung server error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do on a hung server took %v", elapsed)
+	}
+	if !c.Broken() {
+		t.Error("connection usable after a timeout")
+	}
+}
+
+// TestPoolHungServer checks that the health check of a hung server's
+// connections times out, so that Get and Close return
+func TestPoolHungServer(t *testing.T) {
+	p := NewPool(fakeServer(t, ""))
+	p.MinIdle = 2
+	p.HealthCheckInterval = 20 * time.Millisecond
+	p.Options = Options{ReadTimeout: 50 * time.Millisecond}
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(c)
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		if c, err := p.Get(); err == nil {
+			p.Put(c)
+		}
+		p.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Get and Close blocked on a hung server")
+	}
+}
@@ -0,0 +1,268 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+)
+
+const (
+	// DefaultMaxIdle is the number of idle connections a Pool keeps when
+	// MaxIdle is zero
+	DefaultMaxIdle = 8
+	// DefaultHealthCheckInterval is used when HealthCheckInterval is zero
+	DefaultHealthCheckInterval = 30 * time.Second
+)
+
+// ErrPoolClosed is returned by Get once the pool is closed
+var ErrPoolClosed = errors.New("connection pool closed")
+
+// Pool keeps connections to one server for reuse. Connections are taken
+// with Get and handed back with Put; broken ones are closed rather than
+// reused, so a server that restarts is reconnected to transparently.
+// Options must be set before the first Get.
+//
+//	pool := client.NewPool("localhost:6379")
+//	c, err := pool.Get()
+//	if err != nil {
+//		return err
+//	}
+//	defer pool.Put(c)
+//	reply, err := c.Do("GET", "key")
+type Pool struct {
+	// Addr is the address of the server
+	Addr string
+	// Dial opens a new connection, for example to send AUTH first.
+	// Defaults to DialOptions with Options.
+	Dial func(addr string) (*Conn, error)
+	// Options configures the connections opened when Dial is nil. Its
+	// timeouts also bound the health check's PING, so that a hung server
+	// cannot stall Get or Close.
+	Options Options
+	// MinIdle is the number of idle connections the health check keeps
+	// open, dialing new ones as needed
+	MinIdle int
+	// MaxIdle caps the idle connections; connections put back beyond it
+	// are closed. Defaults to DefaultMaxIdle.
+	MaxIdle int
+	// HealthCheckInterval is how often idle connections are checked with
+	// PING, and how long a connection may sit idle before Get checks it
+	// first. Dead connections are evicted. Defaults to
+	// DefaultHealthCheckInterval.
+	HealthCheckInterval time.Duration
+
+	mu     sync.Mutex
+	idle   []idleConn // most recently used last
+	closed bool
+
+	start sync.Once
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// idleConn is a connection waiting in the pool
+type idleConn struct {
+	conn  *Conn
+	since time.Time
+}
+
+// NewPool creates a pool of connections to addr. Connections are dialed
+// on demand; the background health check starts with the first Get.
+func NewPool(addr string) *Pool {
+	return &Pool{
+		Addr: addr,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Get returns an idle connection, or dials a new one if none is idle.
+// A connection idle for longer than HealthCheckInterval is pinged first and
+// evicted if it does not answer.
+func (p *Pool) Get() (*Conn, error) {
+	p.start.Do(func() { go p.run() })
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			return p.dial()
+		}
+		ic := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if time.Since(ic.since) < p.healthCheckInterval() || alive(ic.conn) {
+			return ic.conn, nil
+		}
+		ic.conn.Close()
+	}
+}
+
+// Put hands a connection taken with Get back to the pool. Broken
+// connections, and those beyond MaxIdle, are closed.
+func (p *Pool) Put(c *Conn) {
+	if c == nil {
+		return
+	}
+	if c.Broken() {
+		c.Close()
+		return
+	}
+
+	p.mu.Lock()
+	if p.closed || len(p.idle) >= p.maxIdle() {
+		p.mu.Unlock()
+		c.Close()
+		return
+	}
+	p.idle = append(p.idle, idleConn{conn: c, since: time.Now()})
+	p.mu.Unlock()
+}
+
+// Do sends a command on a pooled connection and returns its reply, as
+// Conn.Do does
+func (p *Pool) Do(args ...string) (*resp.Reply, error) {
+	c, err := p.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer p.Put(c)
+
+	return c.Do(args...)
+}
+
+// Idle returns the number of idle connections
+func (p *Pool) Idle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.idle)
+}
+
+// Close closes the idle connections and stops the health check.
+// Connections still borrowed are closed when they are put back.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	close(p.stop)
+	// Marks the health check as finished if it never started
+	p.start.Do(func() { close(p.done) })
+	<-p.done
+
+	for _, ic := range idle {
+		ic.conn.Close()
+	}
+	return nil
+}
+
+// run checks the idle connections on every tick until the pool is closed
+func (p *Pool) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.healthCheckInterval())
+	defer ticker.Stop()
+
+	p.fill()
+	for {
+		select {
+		case <-ticker.C:
+			p.check()
+			p.fill()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// check pings the connections that have been idle for a whole interval
+// and closes those that do not answer
+func (p *Pool) check() {
+	cutoff := time.Now().Add(-p.healthCheckInterval())
+
+	// Stale connections are taken out so they are not borrowed mid-check
+	p.mu.Lock()
+	var stale []*Conn
+	fresh := p.idle[:0]
+	for _, ic := range p.idle {
+		if ic.since.After(cutoff) {
+			fresh = append(fresh, ic)
+		} else {
+			stale = append(stale, ic.conn)
+		}
+	}
+	p.idle = fresh
+	p.mu.Unlock()
+
+	for _, c := range stale {
+		if alive(c) {
+			p.Put(c)
+		} else {
+			c.Close()
+		}
+	}
+}
+
+// fill dials connections until MinIdle are idle. Dial failures are left
+// for the next tick.
+func (p *Pool) fill() {
+	want := min(p.MinIdle, p.maxIdle())
+	for {
+		p.mu.Lock()
+		done := p.closed || len(p.idle) >= want
+		p.mu.Unlock()
+		if done {
+			return
+		}
+
+		c, err := p.dial()
+		if err != nil {
+			return
+		}
+		p.Put(c)
+	}
+}
+
+// dial opens a new connection
+func (p *Pool) dial() (*Conn, error) {
+	if p.Dial != nil {
+		return p.Dial(p.Addr)
+	}
+	return DialOptions(p.Addr, p.Options)
+}
+
+// maxIdle returns MaxIdle or its default
+func (p *Pool) maxIdle() int {
+	if p.MaxIdle > 0 {
+		return p.MaxIdle
+	}
+	return DefaultMaxIdle
+}
+
+// healthCheckInterval returns HealthCheckInterval or its default
+func (p *Pool) healthCheckInterval() time.Duration {
+	if p.HealthCheckInterval > 0 {
+		return p.HealthCheckInterval
+	}
+	return DefaultHealthCheckInterval
+}
+
+// alive reports whether c answers a PING
+func alive(c *Conn) bool {
+	_, err := c.Do("PING")
+	return err == nil
+}
@@ -0,0 +1,48 @@
+package command
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// MaxPageSize bounds the number of items a paginated command returns. It is
+// the count used when no LIMIT is given and the cap applied to larger counts.
+const MaxPageSize = 1000
+
+// ErrInvalidLimit is returned by ParseLimit for a malformed LIMIT clause
+var ErrInvalidLimit = errors.New("LIMIT requires a non-negative offset and count")
+
+// ParseLimit extracts a "LIMIT offset count" clause from args. The keyword is
+// matched case-insensitively and may appear anywhere; the remaining
+// arguments are returned in order. Without a LIMIT clause offset is 0 and
+// count is MaxPageSize. Counts above MaxPageSize are capped.
+func ParseLimit(args []string) (offset, count int, rest []string, err error) {
+	count = MaxPageSize
+	rest = make([]string, 0, len(args))
+	found := false
+
+	for i := 0; i < len(args); i++ {
+		if !strings.EqualFold(args[i], "LIMIT") {
+			rest = append(rest, args[i])
+			continue
+		}
+		if found || i+2 >= len(args) {
+			return 0, 0, nil, ErrInvalidLimit
+		}
+
+		offset, err = strconv.Atoi(args[i+1])
+		if err != nil || offset < 0 {
+			return 0, 0, nil, ErrInvalidLimit
+		}
+		count, err = strconv.Atoi(args[i+2])
+		if err != nil || count < 0 {
+			return 0, 0, nil, ErrInvalidLimit
+		}
+
+		found = true
+		i += 2
+	}
+
+	return offset, min(count, MaxPageSize), rest, nil
+}
@@ -0,0 +1,149 @@
+package command
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ArgType is the declared type of a command argument
+type ArgType int
+
+const (
+	// ArgString accepts any value
+	ArgString ArgType = iota
+	// ArgKey accepts any non-empty value naming a key
+	ArgKey
+	// ArgInt accepts a base-10 64-bit integer
+	ArgInt
+	// ArgFloat accepts a finite decimal number
+	ArgFloat
+	// ArgTimestamp accepts an RFC3339 timestamp
+	ArgTimestamp
+)
+
+// String returns the name used for the type in error messages
+func (t ArgType) String() string {
+	switch t {
+	case ArgString:
+		return "string"
+	case ArgKey:
+		return "key"
+	case ArgInt:
+		return "integer"
+	case ArgFloat:
+		return "float"
+	case ArgTimestamp:
+		return "RFC3339 timestamp"
+	default:
+		return fmt.Sprintf("ArgType(%d)", int(t))
+	}
+}
+
+// usageName returns the word standing for an unnamed argument of the type
+// in usage lines
+func (t ArgType) usageName() string {
+	switch t {
+	case ArgString:
+		return "arg"
+	case ArgFloat:
+		return "number"
+	case ArgTimestamp:
+		return "timestamp"
+	default:
+		return t.String()
+	}
+}
+
+// ArgError reports an argument that does not match its declared type
+type ArgError struct {
+	Pos   int // position in Context.Args
+	Type  ArgType
+	Value string
+}
+
+func (e *ArgError) Error() string {
+	return fmt.Sprintf("ERR invalid argument %d: %q is not a valid %s", e.Pos, e.Value, e.Type)
+}
+
+// Unwrap returns ErrInvalidArgType so that errors.Is matches it
+func (e *ArgError) Unwrap() error {
+	return ErrInvalidArgType
+}
+
+// BindArgs checks the arguments against spec, where spec[0] describes
+// Args[1], and stores the parsed values for the typed accessors. Arguments
+// beyond the spec are not checked. The server calls it with the command's
+// ArgSpec before the handler runs.
+func (c *Context) BindArgs(spec []ArgType) error {
+	if len(spec) == 0 {
+		return nil
+	}
+
+	values := make([]interface{}, len(c.Args))
+	for i, typ := range spec {
+		pos := i + 1
+		if pos >= len(c.Args) {
+			break
+		}
+
+		v, err := parseArg(typ, c.Args[pos])
+		if err != nil {
+			return &ArgError{Pos: pos, Type: typ, Value: c.Args[pos]}
+		}
+		values[pos] = v
+	}
+	c.values = values
+	return nil
+}
+
+// parseArg converts s to the Go type of typ
+func parseArg(typ ArgType, s string) (interface{}, error) {
+	switch typ {
+	case ArgKey:
+		if s == "" {
+			return nil, ErrInvalidArgType
+		}
+		return s, nil
+	case ArgInt:
+		return strconv.ParseInt(s, 10, 64)
+	case ArgFloat:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, ErrInvalidArgType
+		}
+		return f, nil
+	case ArgTimestamp:
+		return time.Parse(time.RFC3339, s)
+	default:
+		return s, nil
+	}
+}
+
+// Int returns argument i as declared ArgInt, or 0 if it was not
+func (c *Context) Int(i int) int64 {
+	v, _ := c.value(i).(int64)
+	return v
+}
+
+// Float returns argument i as declared ArgFloat, or 0 if it was not
+func (c *Context) Float(i int) float64 {
+	v, _ := c.value(i).(float64)
+	return v
+}
+
+// Time returns argument i as declared ArgTimestamp, or the zero time if it
+// was not
+func (c *Context) Time(i int) time.Time {
+	v, _ := c.value(i).(time.Time)
+	return v
+}
+
+// value returns the parsed value of argument i, or nil
+func (c *Context) value(i int) interface{} {
+	if i < 0 || i >= len(c.values) {
+		return nil
+	}
+	return c.values[i]
+}
@@ -0,0 +1,73 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrArrayLength is returned by ArrayReply when more or fewer elements are
+// written than the array was started with
+var ErrArrayLength = errors.New("array reply length mismatch")
+
+// ArrayReply writes the elements of an array reply started with
+// BeginArray and checks that their number matches the length announced.
+// Errors are sticky: once a write fails, later calls return the same error.
+type ArrayReply struct {
+	ctx    *Context
+	length int // -1 if not known up front
+	added  int
+	err    error
+}
+
+// BeginArray starts an array reply of length elements, written with Add
+// and finished with Close. A negative length starts an array whose length
+// is not known up front, as with BeginStream.
+//
+//	arr := ctx.BeginArray(len(points))
+//	for _, p := range points {
+//		arr.Add(p.Value)
+//	}
+//	return arr.Close()
+func (c *Context) BeginArray(length int) *ArrayReply {
+	a := &ArrayReply{ctx: c, length: length}
+	if length < 0 {
+		a.length = -1
+		a.err = c.BeginStream()
+	} else {
+		a.err = c.ReplyArray(length)
+	}
+	return a
+}
+
+// Add writes the next element, as ReplyValue does. Elements past the
+// announced length are not written and fail with ErrArrayLength.
+func (a *ArrayReply) Add(value interface{}) error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.length >= 0 && a.added == a.length {
+		a.err = fmt.Errorf("%w: more than %d elements", ErrArrayLength, a.length)
+		return a.err
+	}
+	a.added++
+	a.err = a.ctx.ReplyValue(value)
+	return a.err
+}
+
+// Close finishes the array. It fails with ErrArrayLength if fewer elements
+// were added than announced, and with the first error of Add, if any.
+// Returning its error from the handler makes the server drop the client
+// rather than leave it with an incomplete reply.
+func (a *ArrayReply) Close() error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.length < 0 {
+		a.err = a.ctx.EndStream()
+		return a.err
+	}
+	if a.added != a.length {
+		a.err = fmt.Errorf("%w: %d of %d elements written", ErrArrayLength, a.added, a.length)
+	}
+	return a.err
+}
@@ -0,0 +1,24 @@
+package command
+
+// CompareAndSet returns a write command, conventionally named <PREFIX>.CAS,
+// taking a key, the expected value and a new value. It calls cas, which
+// should store the new value only if the key holds the expected one, in a
+// single atomic step such as store.CompareAndSet, and replies 1 if the
+// value was set and 0 otherwise. This gives clients the "SET key value IFEQ
+// expected" check needed for locks and optimistic concurrency without a
+// race between reading the value and writing it.
+func CompareAndSet(name string, cas func(key, expected, value string) bool) *Command {
+	cmd := New(name)
+	cmd.Description = "Set a key's value only if it holds the expected value"
+	cmd.Flags = FlagWrite
+	cmd.MinArgs = 3
+	cmd.MaxArgs = 3
+	cmd.ArgSpec = []ArgType{ArgKey, ArgString, ArgString}
+	cmd.Handler = func(ctx *Context) error {
+		if cas(ctx.Args[1], ctx.Args[2], ctx.Args[3]) {
+			return ctx.ReplyInt(1)
+		}
+		return ctx.ReplyInt(0)
+	}
+	return cmd
+}
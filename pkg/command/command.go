@@ -2,8 +2,14 @@ package command
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
 )
 
 // Common errors
@@ -11,6 +17,10 @@ var (
 	ErrInvalidArgCount = errors.New("invalid number of arguments")
 	ErrInvalidArgType  = errors.New("invalid argument type")
 	ErrCommandNotFound = errors.New("command not found")
+
+	// ErrReplyAborted is returned by a handler that started a reply it
+	// cannot finish. The connection is closed without logging an error.
+	ErrReplyAborted = errors.New("reply aborted")
 )
 
 // Context represents the execution context for a Redis command
@@ -18,10 +28,16 @@ type Context struct {
 	ctx     context.Context
 	Args    []string
 	Conn    RedisConn
+	Session *Session
+	// TraceID identifies this command execution in logs and traces. It is
+	// sent by the client or generated by the server.
+	TraceID string
 	command *Command
+	values  []interface{} // arguments parsed by BindArgs
 }
 
-// RedisConn represents a connection to Redis
+// RedisConn represents a connection to Redis. Connections that can send
+// other replies also implement optional interfaces such as MapConn.
 type RedisConn interface {
 	WriteString(s string) error
 	WriteInt(i int64) error
@@ -31,17 +47,89 @@ type RedisConn interface {
 	Flush() error
 }
 
-// HandlerFunc defines the function signature for command handlers
+// HandlerFunc defines the function signature for command handlers.
+//
+// An error returned before anything was written is sent to the client as an
+// error reply. Once a handler has started its reply, for example with
+// ReplyArray, it must write the complete reply: an error returned after that
+// point cannot be sent without corrupting the frame, so the connection is
+// closed instead. Handlers that cannot finish a started reply should return
+// ErrReplyAborted.
 type HandlerFunc func(ctx *Context) error
 
-// Command represents a Redis command
+// ContextHandlerFunc is a handler that takes the command's context.Context,
+// the one returned by Context.Context, as its first parameter so that it
+// can be passed straight to calls that honor cancellation. Replies follow
+// the same rules as for HandlerFunc.
+type ContextHandlerFunc func(ctx context.Context, c *Context) error
+
+// Flag describes a property of a command
+type Flag uint
+
+const (
+	// FlagWrite marks a command that modifies state. Write commands are
+	// appended to the AOF when it is enabled.
+	FlagWrite Flag = 1 << iota
+	// FlagReadOnly marks a command that only reads state
+	FlagReadOnly
+	// FlagConcurrent marks a command whose handler may run at the same
+	// time as the other commands a client has pipelined, once the server
+	// allows it with ConcurrentCommands. Replies still reach the client in
+	// order. The handler must not depend on the connection's earlier
+	// commands having finished, nor read from its RawReader.
+	FlagConcurrent
+)
+
+// Command represents a Redis command.
+//
+// The fields configure the command and must not be modified once it has
+// been added to an Extension that is being served, since dispatch reads
+// them concurrently. The one exception is the handler, which can be
+// replaced at any time with SetHandler or SetHandlerCtx.
 type Command struct {
-	Name        string
-	Handler     HandlerFunc
-	MinArgs     int
-	MaxArgs     int
+	Name    string
+	Handler HandlerFunc
+	// HandlerCtx is used instead of Handler for handlers written as
+	// ContextHandlerFunc. Exactly one of the two must be set.
+	HandlerCtx ContextHandlerFunc
+	// MinArgs and MaxArgs bound the number of arguments following the
+	// command name. Calls outside the bounds are rejected before the
+	// handler runs.
+	MinArgs int
+	MaxArgs int
+	// ArgSpec declares the types of the arguments following the command
+	// name. They are validated before the handler runs and the parsed
+	// values are available through Context.Int, Context.Float and
+	// Context.Time.
+	ArgSpec []ArgType
+	// ArgNames names the arguments in the usage line given by Usage,
+	// such as "timestamp". Arguments without a name are named after their
+	// ArgSpec type. Names past the declared arguments describe optional
+	// trailing ones as written, such as "[LABELS label value ...]".
+	ArgNames []string
+	// Keys locates the keys among the arguments for COMMAND INFO and
+	// COMMAND GETKEYS, which cluster-aware clients use for routing, and for
+	// ordering writes to the AOF. If nil, the positions of ArgKey arguments
+	// in ArgSpec are used, and a command without either takes no keys.
+	// Commands whose first argument is a key, the convention of module
+	// commands such as TS.STATS, set it to &KeySpec{First: 1, Last: 1, Step: 1}.
+	Keys        *KeySpec
 	Description string
-	mu          sync.RWMutex
+	Flags       Flag
+	// Deprecated, if not empty, marks the command as deprecated. It should
+	// tell clients what to use instead, such as "use TS.RANGE".
+	Deprecated string
+	mu         sync.RWMutex // guards Handler and HandlerCtx after registration
+}
+
+// KeySpec gives the positions of a command's keys in redis's first, last
+// and step notation, counting the command name as position 0. A negative
+// Last counts from the end, -1 being the last argument. All zero means the
+// command takes no keys.
+type KeySpec struct {
+	First int
+	Last  int
+	Step  int
 }
 
 // New creates a new Command instance
@@ -53,40 +141,539 @@ func New(name string) *Command {
 	}
 }
 
+// HasFlag reports whether the command has the given flag set
+func (c *Command) HasFlag(f Flag) bool {
+	return c.Flags&f != 0
+}
+
+// SetHandler replaces the command's handler, which must not be nil. Unlike
+// assigning Handler, it is safe to call while the command is being served;
+// calls already in progress finish with the previous handler.
+func (c *Command) SetHandler(h HandlerFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Handler = h
+	c.HandlerCtx = nil
+}
+
+// SetHandlerCtx is SetHandler for a ContextHandlerFunc
+func (c *Command) SetHandlerCtx(h ContextHandlerFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Handler = nil
+	c.HandlerCtx = h
+}
+
+// Run calls the command's current handler, passing a ContextHandlerFunc
+// the execution's context
+func (c *Command) Run(ctx *Context) error {
+	c.mu.RLock()
+	h, hctx := c.Handler, c.HandlerCtx
+	c.mu.RUnlock()
+
+	if h == nil {
+		return hctx(ctx.Context(), ctx)
+	}
+	return h(ctx)
+}
+
+// CheckArity reports whether n arguments, not counting the command name,
+// are within the command's bounds
+func (c *Command) CheckArity(n int) bool {
+	return n >= c.MinArgs && (c.MaxArgs < 0 || n <= c.MaxArgs)
+}
+
+// WantsHelp reports whether args, the command name followed by its
+// arguments, are a lone HELP that the command cannot take as its argument,
+// so that the caller should reply with the usage line instead of running
+// it. That is the case when the command does not take exactly one
+// argument or its ArgSpec rejects HELP, as ArgInt does. A command taking
+// a key or string, such as TS.STATS key, runs with HELP as its argument.
+func (c *Command) WantsHelp(args []string) bool {
+	if len(args) != 2 || !strings.EqualFold(args[1], "HELP") {
+		return false
+	}
+	if !c.CheckArity(1) {
+		return true
+	}
+	if len(c.ArgSpec) > 0 {
+		_, err := parseArg(c.ArgSpec[0], args[1])
+		return err != nil
+	}
+	return false
+}
+
+// Arity returns the command's arity in redis notation: the exact number of
+// words including the command name, or its negated minimum when the command
+// accepts a variable number of arguments
+func (c *Command) Arity() int {
+	if c.MaxArgs == c.MinArgs {
+		return c.MinArgs + 1
+	}
+	return -(c.MinArgs + 1)
+}
+
+// Usage returns the command's usage line, such as "TS.ADD key timestamp
+// value [LABELS label value ...]", generated from ArgSpec, ArgNames and the
+// argument bounds. name is the name the command was invoked as, which
+// carries the extension's namespace prefix.
+func (c *Command) Usage(name string) string {
+	words := []string{strings.ToUpper(name)}
+	declared := max(len(c.ArgSpec), c.MinArgs)
+	for i := 0; i < declared; i++ {
+		switch {
+		case i < len(c.ArgNames):
+			words = append(words, c.ArgNames[i])
+		case i < len(c.ArgSpec):
+			words = append(words, c.ArgSpec[i].usageName())
+		default:
+			words = append(words, "arg")
+		}
+	}
+	if len(c.ArgNames) > declared {
+		words = append(words, c.ArgNames[declared:]...)
+	} else if c.MaxArgs < 0 || c.MaxArgs > declared {
+		words = append(words, "[arg ...]")
+	}
+	return strings.Join(words, " ")
+}
+
+// ArityError returns the error replied when the command is invoked as name
+// with the wrong number of arguments. It includes the usage line for
+// commands that declare their arguments with ArgSpec or ArgNames.
+func (c *Command) ArityError(name string) error {
+	msg := fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+	if len(c.ArgSpec) > 0 || len(c.ArgNames) > 0 {
+		msg += ", usage: " + c.Usage(name)
+	}
+	return errors.New(msg)
+}
+
+// KeyPositions returns the command's key positions as described by Keys
+func (c *Command) KeyPositions() KeySpec {
+	if c.Keys != nil {
+		return *c.Keys
+	}
+	var keys []int
+	for i, typ := range c.ArgSpec {
+		if typ == ArgKey {
+			keys = append(keys, i+1)
+		}
+	}
+	switch len(keys) {
+	case 0:
+		return KeySpec{}
+	case 1:
+		return KeySpec{First: keys[0], Last: keys[0], Step: 1}
+	default:
+		return KeySpec{First: keys[0], Last: keys[len(keys)-1], Step: keys[1] - keys[0]}
+	}
+}
+
+// KeyArgs returns the keys of an invocation of the command, args holding
+// the command name followed by its arguments, at the positions given by
+// KeyPositions
+func (c *Command) KeyArgs(args []string) []string {
+	spec := c.KeyPositions()
+	if spec.First <= 0 || spec.First >= len(args) {
+		return nil
+	}
+	last := spec.Last
+	if last < 0 {
+		last += len(args)
+	}
+	last = min(last, len(args)-1)
+	step := max(spec.Step, 1)
+
+	var keys []string
+	for i := spec.First; i <= last; i += step {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
+
+// Context returns the context of the command execution. It carries the
+// server's command deadline, if any, and is never nil.
+func (c *Context) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// SetContext sets the context returned by Context. The server calls it
+// before running the handler.
+func (c *Context) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// DB returns the logical database selected by the client with SELECT.
+// Extensions serving several databases use it to pick the store to act on.
+func (c *Context) DB() int {
+	if c.Session == nil {
+		return 0
+	}
+	return c.Session.DB()
+}
+
 // Reply sends a string response back to Redis
 func (c *Context) Reply(s string) error {
 	return c.Conn.WriteString(s)
 }
 
+// ReplyStatus sends a status reply, a simple string such as +OK, the way
+// redis acknowledges commands like SET. Use Reply for data values, which
+// are sent as bulk strings. s must not contain CR or LF. Connections that
+// are not a StatusConn receive a bulk string.
+func (c *Context) ReplyStatus(s string) error {
+	if sc, ok := c.Conn.(StatusConn); ok {
+		return sc.WriteStatus(s)
+	}
+	return c.Conn.WriteString(s)
+}
+
 // ReplyInt sends an integer response back to Redis
 func (c *Context) ReplyInt(i int64) error {
 	return c.Conn.WriteInt(i)
 }
 
+// ReplyFloat sends a floating point response back to Redis. RESP3 clients
+// receive a native double; RESP2 clients receive the decimal as a bulk string.
+func (c *Context) ReplyFloat(f float64) error {
+	if fc, ok := c.Conn.(FloatConn); ok {
+		return fc.WriteFloat(f)
+	}
+	return c.Conn.WriteString(resp.FormatDouble(f))
+}
+
+// ReplyBool sends a boolean response back to Redis. RESP3 clients receive a
+// native boolean; RESP2 clients receive the integer 1 or 0.
+func (c *Context) ReplyBool(b bool) error {
+	if bc, ok := c.Conn.(BoolConn); ok {
+		return bc.WriteBool(b)
+	}
+	if b {
+		return c.Conn.WriteInt(1)
+	}
+	return c.Conn.WriteInt(0)
+}
+
 // ReplyArray starts an array response with the given length
 func (c *Context) ReplyArray(length int) error {
 	return c.Conn.WriteArray(length)
 }
 
+// ReplyMap starts a map response with the given number of key/value pairs.
+// RESP2 clients receive a flat array of alternating keys and values.
+func (c *Context) ReplyMap(length int) error {
+	if mc, ok := c.Conn.(MapConn); ok {
+		return mc.WriteMap(length)
+	}
+	return c.Conn.WriteArray(2 * length)
+}
+
+// ReplyRaw sends p, a reply encoded beforehand, such as one kept by a
+// cache, without re-encoding it. p must hold exactly one complete RESP
+// value encoded for the client's protocol version (see Session.Protocol);
+// it is not checked, and anything else desynchronizes the client.
+func (c *Context) ReplyRaw(p []byte) error {
+	if rc, ok := c.Conn.(RawReplyConn); ok {
+		return rc.WriteRaw(p)
+	}
+	return ErrReplyUnsupported
+}
+
+// ReplySet starts a set response with the given number of elements.
+// RESP2 clients receive an array.
+func (c *Context) ReplySet(length int) error {
+	if sc, ok := c.Conn.(SetConn); ok {
+		return sc.WriteSet(length)
+	}
+	return c.Conn.WriteArray(length)
+}
+
+// ReplyStringSet sends members as a set, for unordered collections such as
+// tags, so RESP3 clients know their order is meaningless. Duplicates are
+// sent once. RESP2 clients receive an array.
+func (c *Context) ReplyStringSet(members []string) error {
+	seen := make(map[string]bool, len(members))
+	unique := members[:0:0]
+	for _, member := range members {
+		if !seen[member] {
+			seen[member] = true
+			unique = append(unique, member)
+		}
+	}
+
+	if err := c.ReplySet(len(unique)); err != nil {
+		return err
+	}
+	for _, member := range unique {
+		if err := c.Reply(member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplyStringMap sends fields the way HGETALL does: a map of field names
+// to values, or a flat array of names and values for RESP2 clients. Fields
+// are sent sorted by name so that identical hashes give the same reply. A
+// nil map, as for a missing key, is sent as an empty map.
+func (c *Context) ReplyStringMap(fields map[string]string) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := c.ReplyMap(len(names)); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := c.Reply(name); err != nil {
+			return err
+		}
+		if err := c.Reply(fields[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplyEmptyArray sends an empty array, which clients tell apart from a
+// null reply
+func (c *Context) ReplyEmptyArray() error {
+	return c.ReplyArray(0)
+}
+
+// ReplyEmptyMap sends an empty map, or an empty array to RESP2 clients
+func (c *Context) ReplyEmptyMap() error {
+	return c.ReplyMap(0)
+}
+
+// ReplyEmptySet sends an empty set, or an empty array to RESP2 clients
+func (c *Context) ReplyEmptySet() error {
+	return c.ReplySet(0)
+}
+
+// ReplyScoredMembers sends a ranked list the way ZRANGE does. Without
+// scores it is an array of members. With scores, RESP2 clients receive a
+// flat array alternating members and scores, and RESP3 clients an array of
+// [member, score] pairs. scores must be as long as members when withScores
+// is set.
+func (c *Context) ReplyScoredMembers(members []string, scores []float64, withScores bool) error {
+	if !withScores {
+		return c.ReplyValue(members)
+	}
+	if len(scores) != len(members) {
+		return fmt.Errorf("got %d scores for %d members", len(scores), len(members))
+	}
+
+	pairs := c.Session != nil && c.Session.Protocol() >= resp.RESP3
+	if pairs {
+		if err := c.ReplyArray(len(members)); err != nil {
+			return err
+		}
+	} else if err := c.ReplyArray(2 * len(members)); err != nil {
+		return err
+	}
+	for i, member := range members {
+		if pairs {
+			if err := c.ReplyArray(2); err != nil {
+				return err
+			}
+		}
+		if err := c.Reply(member); err != nil {
+			return err
+		}
+		if err := c.ReplyFloat(scores[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplyCompressed sends a bulk payload that is gzip-compressed when the
+// client negotiated compression and the payload is large enough to benefit.
+// Otherwise the payload is sent as a plain bulk string.
+func (c *Context) ReplyCompressed(p []byte) error {
+	if cc, ok := c.Conn.(CompressedConn); ok {
+		return cc.WriteCompressed(p)
+	}
+	return c.Conn.WriteString(string(p))
+}
+
+// ReplyVerbatim sends text tagged with a three character format such as
+// "txt" or "mkd". RESP2 clients receive a plain bulk string.
+func (c *Context) ReplyVerbatim(format, s string) error {
+	if vc, ok := c.Conn.(VerbatimConn); ok {
+		return vc.WriteVerbatim(format, s)
+	}
+	return c.Conn.WriteString(s)
+}
+
+// ReplyValue sends a Go value encoded as the matching RESP type. See
+// resp.Writer.WriteValue for the supported types.
+func (c *Context) ReplyValue(v interface{}) error {
+	if vc, ok := c.Conn.(ValueConn); ok {
+		return vc.WriteValue(v)
+	}
+	return ErrReplyUnsupported
+}
+
+// ReplyStruct sends a struct as a map of its field names to their values,
+// named by their json tags, so that clients get structured data rather
+// than JSON in a bulk string. RESP2 clients receive a flat array of
+// alternating names and values. See resp.Writer.WriteStruct for the
+// supported field types.
+func (c *Context) ReplyStruct(v interface{}) error {
+	if sc, ok := c.Conn.(StructConn); ok {
+		return sc.WriteStruct(v)
+	}
+	return ErrReplyUnsupported
+}
+
+// ReplyJSON sends JSON-modeled data as the equivalent RESP value, so that
+// clients get typed values rather than JSON in a bulk string: objects are
+// sent as maps, arrays as arrays and true and false as booleans. v is a
+// json.RawMessage holding a JSON document, or any value json.Marshal
+// accepts, such as a decoded map[string]interface{}. RESP2 clients receive
+// maps as flat arrays. See resp.Writer.WriteJSON for the encoding.
+func (c *Context) ReplyJSON(v interface{}) error {
+	data, ok := v.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	if jc, ok := c.Conn.(JSONConn); ok {
+		return jc.WriteJSON(data)
+	}
+	return ErrReplyUnsupported
+}
+
+// BeginStream starts an array reply whose length is not known up front.
+// Each element is sent with ReplyValue and the array is finished with
+// EndStream. RESP3 clients receive elements as they are written; for RESP2
+// clients the elements are buffered and sent when the stream ends.
+func (c *Context) BeginStream() error {
+	if sc, ok := c.Conn.(StreamConn); ok {
+		return sc.BeginStream()
+	}
+	return ErrReplyUnsupported
+}
+
+// EndStream finishes an array reply started by BeginStream
+func (c *Context) EndStream() error {
+	if sc, ok := c.Conn.(StreamConn); ok {
+		return sc.EndStream()
+	}
+	return ErrReplyUnsupported
+}
+
+// SetReplyAttribute attaches a RESP3 attribute to the reply, such as a
+// retry-after hint alongside a rejection. Attributes are sent in a single
+// attribute frame immediately before the reply and are silently dropped for
+// RESP2 clients. They must be set before the reply is started.
+func (c *Context) SetReplyAttribute(key string, value interface{}) {
+	if ac, ok := c.Conn.(AttributeConn); ok {
+		ac.SetAttribute(key, value)
+	}
+}
+
 // ReplyNull sends a null response back to Redis
 func (c *Context) ReplyNull() error {
 	return c.Conn.WriteNull()
 }
 
+// ReplyNotFound sends a null reply for a lookup that found nothing, such
+// as a missing key. Missing data is a normal result, not a failure: use
+// ReplyNotFound for it and reserve errors, whether returned or sent with
+// ReplyError, for malformed requests and operations that failed. Clients
+// can then tell "no such key" apart from "something broke".
+func (c *Context) ReplyNotFound() error {
+	return c.Conn.WriteNull()
+}
+
 // ReplyError sends an error response back to Redis
 func (c *Context) ReplyError(err error) error {
 	return c.Conn.WriteError(err)
 }
 
+// CloseAfterReply makes the server close the connection once the handler
+// returns and its reply has been sent. It has no effect on connections
+// that are not a ClosingConn.
+func (c *Context) CloseAfterReply() {
+	if cc, ok := c.Conn.(ClosingConn); ok {
+		cc.CloseAfterReply()
+	}
+}
+
+// ReplyErrorAndClose sends an error response and closes the connection
+// afterwards. It is meant for failures after which the client must not be
+// served further, such as failed authentication or protocol abuse; for
+// ordinary errors return the error or use ReplyError instead.
+func (c *Context) ReplyErrorAndClose(err error) error {
+	c.CloseAfterReply()
+	return c.Conn.WriteError(err)
+}
+
 // Flush ensures all written data is sent to Redis
 func (c *Context) Flush() error {
 	return c.Conn.Flush()
 }
 
+// Push sends an out-of-band RESP3 push frame of the given kind, such as
+// "invalidate", to the client. A push sent while the command runs is
+// delivered after its reply. To push from another goroutine later, keep
+// Conn rather than the Context: the server's connections are a PushConn
+// whose WritePush is safe to call at any time. RESP2 clients cannot
+// receive pushes and resp.ErrPushUnsupported is returned, as it is for
+// connections that are not a PushConn.
+func (c *Context) Push(kind string, elements ...interface{}) error {
+	if pc, ok := c.Conn.(PushConn); ok {
+		return pc.WritePush(kind, elements...)
+	}
+	return resp.ErrPushUnsupported
+}
+
+// RawConn is implemented by connections that give handlers direct access to
+// the protocol stream
+type RawConn interface {
+	RawReader() *resp.Reader
+}
+
+// RawReader returns the reader the command was read from, for handlers
+// that consume frames the client sends after the command itself, such as a
+// bulk load. It returns nil when the command has no client connection, for
+// example during AOF replay.
+//
+// Reading advances the protocol: every frame read here is consumed by the
+// handler and will not be dispatched as a command. A handler must read
+// exactly the frames its client sends; reading too few leaves the rest to
+// be executed as commands and reading too many blocks until the client
+// sends more. Frames read this way are not part of Args, so they are not
+// seen by MONITOR or written to the AOF.
+func (c *Context) RawReader() *resp.Reader {
+	if rc, ok := c.Conn.(RawConn); ok {
+		return rc.RawReader()
+	}
+	return nil
+}
+
 // Extension represents a Redis extension that can contain multiple commands
 type Extension struct {
 	Name     string
-	commands map[string]*Command
+	commands map[string]*Command // keyed by upper-cased name
+	prefix   string
+	fallback HandlerFunc // handles names no command matches
+	router   Router      // replaces the lookup by name if set
+	onStart  []func(ctx context.Context) error
+	onStop   []func() error
 	mu       sync.RWMutex
 }
 
@@ -98,11 +685,73 @@ func NewExtension(name string) *Extension {
 	}
 }
 
-// AddCommand registers a new command with the extension
+// Namespaced makes the extension's commands reachable under the upper-cased
+// extension name as a dotted prefix, so that a command registered as "ADD"
+// on an extension named "ts" is invoked as "TS.ADD". It returns the
+// extension to allow chaining after NewExtension.
+func (e *Extension) Namespaced() *Extension {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.prefix = strings.ToUpper(e.Name) + "."
+	return e
+}
+
+// Prefix returns the dotted namespace prefix, or "" if the extension is
+// not namespaced
+func (e *Extension) Prefix() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.prefix
+}
+
+// AddCommand registers a new command with the extension, replacing any
+// command of the same name. It fails if a command whose name differs only
+// in case is registered, since both would be invoked by the same calls.
 func (e *Extension) AddCommand(cmd *Command) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if err := validate(cmd); err != nil {
+		return err
+	}
+
+	key := strings.ToUpper(cmd.Name)
+	if existing, exists := e.commands[key]; exists && existing.Name != cmd.Name {
+		return fmt.Errorf("command %s is already registered as %s", cmd.Name, existing.Name)
+	}
+	e.commands[key] = cmd
+	return nil
+}
+
+// AddCommands registers several commands at once. If any command is
+// invalid or its name, ignoring case, is already registered or repeated in
+// cmds, none are added and the first error is returned.
+func (e *Extension) AddCommands(cmds ...*Command) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	seen := make(map[string]bool, len(cmds))
+	for _, cmd := range cmds {
+		if err := validate(cmd); err != nil {
+			return err
+		}
+		key := strings.ToUpper(cmd.Name)
+		if _, exists := e.commands[key]; exists || seen[key] {
+			return fmt.Errorf("command %s is already registered", cmd.Name)
+		}
+		seen[key] = true
+	}
+
+	for _, cmd := range cmds {
+		e.commands[strings.ToUpper(cmd.Name)] = cmd
+	}
+	return nil
+}
+
+// validate checks that a command can be registered
+func validate(cmd *Command) error {
 	if cmd == nil {
 		return errors.New("command cannot be nil")
 	}
@@ -111,22 +760,141 @@ func (e *Extension) AddCommand(cmd *Command) error {
 		return errors.New("command name cannot be empty")
 	}
 
-	if cmd.Handler == nil {
+	if cmd.Handler == nil && cmd.HandlerCtx == nil {
 		return errors.New("command handler cannot be nil")
 	}
 
-	e.commands[cmd.Name] = cmd
+	if cmd.Handler != nil && cmd.HandlerCtx != nil {
+		return errors.New("command cannot have both Handler and HandlerCtx")
+	}
 	return nil
 }
 
-// GetCommand retrieves a command by name
+// GetCommand retrieves a command by name, ignoring case as redis does.
+// Commands of a namespaced extension are only found under their prefixed
+// name.
 func (e *Extension) GetCommand(name string) (*Command, error) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	cmd, exists := e.commands[name]
+	name, ok := e.trimPrefix(name)
+	if !ok {
+		return nil, ErrCommandNotFound
+	}
+
+	cmd, exists := e.commands[strings.ToUpper(name)]
 	if !exists {
 		return nil, ErrCommandNotFound
 	}
 	return cmd, nil
 }
+
+// OnStart registers a hook run when a server serving the extension starts,
+// before it accepts connections. Hooks typically start background work
+// such as eviction sweepers; ctx is canceled when the server shuts down.
+// An error aborts the start and is returned by Serve. Hooks of an extension
+// registered after the server started do not run.
+func (e *Extension) OnStart(fn func(ctx context.Context) error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.onStart = append(e.onStart, fn)
+}
+
+// OnStop registers a hook run when a server that started the extension
+// shuts down, after its connections have finished. Hooks run in reverse
+// registration order so that work is torn down in the opposite order it
+// was started.
+func (e *Extension) OnStop(fn func() error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.onStop = append(e.onStop, fn)
+}
+
+// Start runs the extension's start hooks in registration order, stopping
+// at the first error
+func (e *Extension) Start(ctx context.Context) error {
+	e.mu.RLock()
+	hooks := e.onStart
+	e.mu.RUnlock()
+
+	for _, fn := range hooks {
+		if err := fn(ctx); err != nil {
+			return fmt.Errorf("extension %s: %w", e.Name, err)
+		}
+	}
+	return nil
+}
+
+// Stop runs the extension's stop hooks in reverse registration order. All
+// hooks run; the first error is returned.
+func (e *Extension) Stop() error {
+	e.mu.RLock()
+	hooks := e.onStop
+	e.mu.RUnlock()
+
+	var err error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hookErr := hooks[i](); hookErr != nil && err == nil {
+			err = fmt.Errorf("extension %s: %w", e.Name, hookErr)
+		}
+	}
+	return err
+}
+
+// trimPrefix strips the namespace prefix from name, ignoring case. It
+// reports false if the extension is namespaced and name lacks the prefix.
+// The caller must hold e.mu.
+func (e *Extension) trimPrefix(name string) (string, bool) {
+	if e.prefix == "" {
+		return name, true
+	}
+	if len(name) <= len(e.prefix) || !strings.EqualFold(name[:len(e.prefix)], e.prefix) {
+		return "", false
+	}
+	return name[len(e.prefix):], true
+}
+
+// SetDefaultHandler sets a handler for commands that match no command of
+// the extension or of the server, such as a proxy forwarding them upstream.
+// ctx.Args holds the full command as sent, including its name. For a
+// namespaced extension, only names carrying its prefix reach the handler.
+// If several extensions have a default handler, the first registered with
+// the server wins. A nil handler removes it.
+func (e *Extension) SetDefaultHandler(h HandlerFunc) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.fallback = h
+}
+
+// DefaultCommand returns a command running the default handler for name.
+// It fails with ErrCommandNotFound if the extension has no default handler
+// or name lies outside its namespace.
+func (e *Extension) DefaultCommand(name string) (*Command, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, ok := e.trimPrefix(name); !ok || e.fallback == nil {
+		return nil, ErrCommandNotFound
+	}
+
+	cmd := New(strings.ToUpper(name))
+	cmd.Handler = e.fallback
+	return cmd, nil
+}
+
+// CommandNames returns the names clients use to invoke the extension's
+// commands, including any namespace prefix, in sorted order
+func (e *Extension) CommandNames() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	names := make([]string, 0, len(e.commands))
+	for _, cmd := range e.commands {
+		names = append(names, e.prefix+cmd.Name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,74 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddCommandCaseDuplicate(t *testing.T) {
+	handler := func(ctx *Context) error { return ctx.ReplyNull() }
+	newCmd := func(name string) *Command {
+		cmd := New(name)
+		cmd.Handler = handler
+		return cmd
+	}
+
+	ext := NewExtension("test")
+	if err := ext.AddCommand(newCmd("TEST.CMD")); err != nil {
+		t.Fatalf("AddCommand: %v", err)
+	}
+	if err := ext.AddCommand(newCmd("TEST.CMD")); err != nil {
+		t.Errorf("AddCommand with the same name: %v", err)
+	}
+	if err := ext.AddCommand(newCmd("test.cmd")); err == nil {
+		t.Error("AddCommand with a name differing in case succeeded")
+	}
+	if err := ext.AddCommands(newCmd("Test.Cmd")); err == nil {
+		t.Error("AddCommands with a name differing in case succeeded")
+	}
+	if cmd, err := ext.GetCommand("test.cmd"); err != nil || cmd.Name != "TEST.CMD" {
+		t.Errorf("GetCommand = %v, %v, want TEST.CMD", cmd, err)
+	}
+}
+
+func TestKeyPositions(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *Command
+		args []string
+		want []string
+	}{
+		{"no spec", &Command{MinArgs: 1}, []string{"CMD", "a"}, nil},
+		{"Keys", &Command{Keys: &KeySpec{First: 1, Last: 1, Step: 1}}, []string{"CMD", "a", "b"}, []string{"a"}},
+		{"Keys to the end", &Command{Keys: &KeySpec{First: 1, Last: -1, Step: 2}}, []string{"CMD", "a", "1", "b", "2"}, []string{"a", "b"}},
+		{"ArgSpec", &Command{ArgSpec: []ArgType{ArgString, ArgKey}}, []string{"CMD", "x", "a"}, []string{"a"}},
+		{"ArgSpec without keys", &Command{ArgSpec: []ArgType{ArgString}}, []string{"CMD", "x"}, nil},
+	}
+	for _, tt := range tests {
+		if got := tt.cmd.KeyArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: KeyArgs(%q) = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestWantsHelp(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *Command
+		args []string
+		want bool
+	}{
+		{"no arguments allowed", &Command{}, []string{"CMD", "HELP"}, true},
+		{"two arguments needed", &Command{MinArgs: 2, MaxArgs: 2}, []string{"CMD", "help"}, true},
+		{"integer argument", &Command{MinArgs: 1, MaxArgs: 1, ArgSpec: []ArgType{ArgInt}}, []string{"CMD", "HELP"}, true},
+		{"key argument", &Command{MinArgs: 1, MaxArgs: 1, ArgSpec: []ArgType{ArgKey}}, []string{"CMD", "HELP"}, false},
+		{"untyped argument", &Command{MinArgs: 1, MaxArgs: 1}, []string{"CMD", "HELP"}, false},
+		{"other argument", &Command{}, []string{"CMD", "HELPME"}, false},
+		{"more arguments", &Command{MaxArgs: -1}, []string{"CMD", "HELP", "x"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.cmd.WantsHelp(tt.args); got != tt.want {
+			t.Errorf("%s: WantsHelp(%q) = %v, want %v", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
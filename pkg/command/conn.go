@@ -0,0 +1,101 @@
+package command
+
+import "errors"
+
+// ErrReplyUnsupported is returned by Context reply methods that need a
+// capability the connection does not implement and cannot be sent another
+// way
+var ErrReplyUnsupported = errors.New("reply not supported by the connection")
+
+// The interfaces below are optional capabilities of a RedisConn. Context
+// checks for them with a type assertion, so a RedisConn only needs the
+// methods of the replies it can send. Where RESP2 has an equivalent, such
+// as a flat array for a map, Context falls back to it; otherwise it
+// returns ErrReplyUnsupported. The server's connections implement all of
+// them.
+
+// StatusConn is implemented by connections that can send simple strings
+type StatusConn interface {
+	WriteStatus(s string) error
+}
+
+// FloatConn is implemented by connections that can send RESP3 doubles
+type FloatConn interface {
+	WriteFloat(f float64) error
+}
+
+// BoolConn is implemented by connections that can send RESP3 booleans
+type BoolConn interface {
+	WriteBool(b bool) error
+}
+
+// MapConn is implemented by connections that can send RESP3 maps
+type MapConn interface {
+	WriteMap(length int) error
+}
+
+// SetConn is implemented by connections that can send RESP3 sets
+type SetConn interface {
+	WriteSet(length int) error
+}
+
+// VerbatimConn is implemented by connections that can send RESP3 verbatim
+// strings
+type VerbatimConn interface {
+	WriteVerbatim(format, s string) error
+}
+
+// CompressedConn is implemented by connections that can send compressed
+// payloads to clients that negotiated compression
+type CompressedConn interface {
+	WriteCompressed(p []byte) error
+}
+
+// ValueConn is implemented by connections that can encode Go values, see
+// resp.Writer.WriteValue
+type ValueConn interface {
+	WriteValue(v interface{}) error
+}
+
+// StructConn is implemented by connections that can encode structs, see
+// resp.Writer.WriteStruct
+type StructConn interface {
+	WriteStruct(v interface{}) error
+}
+
+// JSONConn is implemented by connections that can encode JSON documents,
+// see resp.Writer.WriteJSON
+type JSONConn interface {
+	WriteJSON(data []byte) error
+}
+
+// RawReplyConn is implemented by connections that can send replies
+// encoded beforehand
+type RawReplyConn interface {
+	WriteRaw(p []byte) error
+}
+
+// StreamConn is implemented by connections that can stream arrays of
+// unknown length
+type StreamConn interface {
+	BeginStream() error
+	EndStream() error
+}
+
+// AttributeConn is implemented by connections that can send RESP3
+// attributes ahead of a reply
+type AttributeConn interface {
+	SetAttribute(key string, value interface{})
+}
+
+// ClosingConn is implemented by connections that can be closed once the
+// current reply is sent
+type ClosingConn interface {
+	CloseAfterReply()
+}
+
+// PushConn is implemented by connections that can receive RESP3 push
+// frames
+type PushConn interface {
+	WritePush(kind string, elements ...interface{}) error
+}
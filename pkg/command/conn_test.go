@@ -0,0 +1,74 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// basicConn implements only RedisConn and records what is written
+type basicConn struct {
+	written []string
+}
+
+func (b *basicConn) WriteString(s string) error  { return b.record("string", s) }
+func (b *basicConn) WriteInt(i int64) error      { return b.record("int", i) }
+func (b *basicConn) WriteArray(length int) error { return b.record("array", length) }
+func (b *basicConn) WriteNull() error            { return b.record("null", nil) }
+func (b *basicConn) WriteError(err error) error  { return b.record("error", err) }
+func (b *basicConn) Flush() error                { return nil }
+
+func (b *basicConn) record(kind string, v interface{}) error {
+	b.written = append(b.written, fmt.Sprint(kind, " ", v))
+	return nil
+}
+
+func TestReplyFallbacks(t *testing.T) {
+	conn := &basicConn{}
+	ctx := &Context{Conn: conn}
+
+	ctx.SetReplyAttribute("ignored", 1)
+	ctx.CloseAfterReply()
+	for _, err := range []error{
+		ctx.ReplyStatus("OK"),
+		ctx.ReplyFloat(1.5),
+		ctx.ReplyBool(true),
+		ctx.ReplyMap(2),
+		ctx.ReplySet(3),
+		ctx.ReplyVerbatim("txt", "text"),
+		ctx.ReplyCompressed([]byte("payload")),
+	} {
+		if err != nil {
+			t.Fatalf("reply failed: %v", err)
+		}
+	}
+
+	want := []string{
+		"string OK",
+		"string 1.5",
+		"int 1",
+		"array 4",
+		"array 3",
+		"string text",
+		"string payload",
+	}
+	if !reflect.DeepEqual(conn.written, want) {
+		t.Errorf("written = %q, want %q", conn.written, want)
+	}
+}
+
+func TestReplyUnsupported(t *testing.T) {
+	ctx := &Context{Conn: &basicConn{}}
+	for name, err := range map[string]error{
+		"ReplyValue":  ctx.ReplyValue("x"),
+		"ReplyStruct": ctx.ReplyStruct(struct{}{}),
+		"ReplyJSON":   ctx.ReplyJSON(map[string]int{"a": 1}),
+		"ReplyRaw":    ctx.ReplyRaw([]byte("+OK\r\n")),
+		"BeginStream": ctx.BeginStream(),
+	} {
+		if !errors.Is(err, ErrReplyUnsupported) {
+			t.Errorf("%s error = %v, want ErrReplyUnsupported", name, err)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package command
+
+import "github.com/aakash-a-dev/Goluxis/pkg/store"
+
+// MemUsage returns a read-only command, conventionally named
+// <PREFIX>.MEMUSAGE, that takes a key and replies with the approximate
+// number of bytes used by the value lookup returns for it, as estimated
+// by store.ApproxSize. Missing keys get a null reply. Values that know
+// their layout should implement store.Sizer for a cheaper, more accurate
+// estimate.
+func MemUsage(name string, lookup func(key string) (interface{}, bool)) *Command {
+	cmd := New(name)
+	cmd.Description = "Report the approximate memory used by a key's value"
+	cmd.Flags = FlagReadOnly
+	cmd.MinArgs = 1
+	cmd.MaxArgs = 1
+	cmd.ArgSpec = []ArgType{ArgKey}
+	cmd.Handler = func(ctx *Context) error {
+		value, exists := lookup(ctx.Args[1])
+		if !exists {
+			return ctx.ReplyNotFound()
+		}
+		return ctx.ReplyInt(int64(store.ApproxSize(value)))
+	}
+	return cmd
+}
@@ -0,0 +1,114 @@
+package command
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/store"
+)
+
+// ErrRateLimited is returned by RateLimitMiddleware for a rejected call
+var ErrRateLimited = errors.New("ERR rate limit exceeded")
+
+// Middleware wraps a handler to run code before or after it, or to reject
+// the call without running it at all
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Chain wraps h in the given middleware. The first middleware is the
+// outermost, so it runs first.
+func Chain(h HandlerFunc, mw ...Middleware) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// tokenBucket holds the tokens left for one rate limiting key
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimitMiddleware allows up to limit calls per window for each key
+// returned by keyFunc and rejects the rest with ErrRateLimited. A nil
+// keyFunc limits all calls together; ClientIP and FirstArg limit per client
+// and per key.
+//
+// Each key has a token bucket holding up to limit tokens that refills at
+// limit per window, so a check takes constant time and bursts of up to
+// limit calls are allowed. Buckets idle for a whole window are full again
+// and are dropped by a sweep run at most once per window by the call that
+// finds it due, so the middleware starts no goroutines.
+func RateLimitMiddleware(limit int, window time.Duration, keyFunc func(*Context) string) Middleware {
+	if limit < 1 || window <= 0 {
+		panic("command: RateLimitMiddleware requires a positive limit and window")
+	}
+
+	rate := float64(limit) / window.Seconds()
+	buckets := store.New[*tokenBucket]()
+	locks := store.NewKeyedMutex(256)
+	var nextSweep atomic.Int64 // in Unix nanoseconds
+
+	allow := func(key string) bool {
+		now := time.Now()
+		if due := nextSweep.Load(); now.UnixNano() >= due &&
+			nextSweep.CompareAndSwap(due, now.Add(window).UnixNano()) {
+			buckets.EvictExpired()
+		}
+
+		locks.Lock(key)
+		defer locks.Unlock(key)
+
+		b, exists := buckets.Get(key)
+		if !exists {
+			b = &tokenBucket{tokens: float64(limit), last: now}
+		} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+			b.tokens = min(float64(limit), b.tokens+elapsed*rate)
+			b.last = now
+		}
+
+		allowed := b.tokens >= 1
+		if allowed {
+			b.tokens--
+		}
+		buckets.SetWithTTL(key, b, window)
+		return allowed
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			var key string
+			if keyFunc != nil {
+				key = keyFunc(ctx)
+			}
+			if !allow(key) {
+				return ErrRateLimited
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// ClientIP returns the IP address of the calling client, for limiting per
+// client with RateLimitMiddleware
+func ClientIP(ctx *Context) string {
+	if ctx.Session == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(ctx.Session.Addr())
+	if err != nil {
+		return ctx.Session.Addr()
+	}
+	return host
+}
+
+// FirstArg returns the first argument after the command name, usually the
+// key, for limiting per key with RateLimitMiddleware
+func FirstArg(ctx *Context) string {
+	if len(ctx.Args) < 2 {
+		return ""
+	}
+	return ctx.Args[1]
+}
@@ -0,0 +1,36 @@
+package command
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	before := runtime.NumGoroutine()
+	var handlers []HandlerFunc
+	for i := 0; i < 100; i++ {
+		mw := RateLimitMiddleware(2, time.Hour, FirstArg)
+		handlers = append(handlers, mw(func(ctx *Context) error { return nil }))
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("building the middleware started %d goroutines", after-before)
+	}
+
+	h := handlers[0]
+	call := func(key string) error {
+		return h(&Context{Args: []string{"CMD", key}})
+	}
+	for i := 0; i < 2; i++ {
+		if err := call("a"); err != nil {
+			t.Fatalf("call %d for a: %v", i+1, err)
+		}
+	}
+	if err := call("a"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("third call for a = %v, want ErrRateLimited", err)
+	}
+	if err := call("b"); err != nil {
+		t.Errorf("first call for b: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+)
+
+// ErrSyntax is wrapped by the errors ParseOptions returns
+var ErrSyntax = resp.ErrSyntax()
+
+// OptionStyle is how an option is written in a command's arguments
+type OptionStyle int
+
+const (
+	// KeywordOption is written redis-style as a keyword followed by its
+	// values, e.g. "AGGREGATION avg 60". A keyword without values is a
+	// flag, e.g. "WITHSCORES".
+	KeywordOption OptionStyle = iota
+	// AssignOption is written as a single name=value argument, e.g.
+	// "brand=acme"
+	AssignOption
+)
+
+// OptionSpec declares an option accepted by ParseOptions
+type OptionSpec struct {
+	Name  string // matched case-insensitively
+	Style OptionStyle
+
+	// Values are the types of the values following a keyword; none makes
+	// the keyword a flag. An AssignOption has one value, of type Values[0]
+	// or ArgString if Values is empty.
+	Values []ArgType
+
+	// Allowed, if set, lists the accepted spellings of the first value,
+	// matched case-insensitively
+	Allowed []string
+
+	Required bool
+}
+
+// Options holds the options given to a command, keyed by upper-cased name
+type Options map[string][]interface{}
+
+// ParseOptions parses args as options declared by specs. Every argument
+// must belong to an option, each option may be given once, and required
+// options must be present. Values are parsed to the Go type of their
+// ArgType, and values matching Allowed are stored in the spelling of the
+// spec.
+func ParseOptions(args []string, specs []OptionSpec) (Options, error) {
+	opts := make(Options)
+	for i := 0; i < len(args); i++ {
+		style, name, raw := KeywordOption, args[i], []string(nil)
+		if key, value, ok := strings.Cut(args[i], "="); ok {
+			style, name, raw = AssignOption, key, []string{value}
+		}
+
+		spec := findOption(specs, style, name)
+		if spec == nil {
+			return nil, fmt.Errorf("%w: unknown option %s", ErrSyntax, args[i])
+		}
+		key := strings.ToUpper(spec.Name)
+		if _, exists := opts[key]; exists {
+			return nil, fmt.Errorf("%w: option %s given more than once", ErrSyntax, spec.Name)
+		}
+
+		types := spec.Values
+		if style == AssignOption && len(types) == 0 {
+			types = []ArgType{ArgString}
+		}
+		if style == KeywordOption {
+			if i+len(types) >= len(args) {
+				return nil, fmt.Errorf("%w: option %s requires %d values", ErrSyntax, spec.Name, len(types))
+			}
+			raw = args[i+1 : i+1+len(types)]
+			i += len(types)
+		}
+
+		values := make([]interface{}, len(types))
+		for j, typ := range types {
+			value, err := parseArg(typ, raw[j])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not a valid %s for option %s", ErrSyntax, raw[j], typ, spec.Name)
+			}
+			values[j] = value
+		}
+		if len(spec.Allowed) > 0 {
+			allowed := false
+			for _, a := range spec.Allowed {
+				if strings.EqualFold(a, raw[0]) {
+					values[0], allowed = a, true
+					break
+				}
+			}
+			if !allowed {
+				return nil, fmt.Errorf("%w: option %s must be one of %s", ErrSyntax, spec.Name, strings.Join(spec.Allowed, ", "))
+			}
+		}
+		opts[key] = values
+	}
+
+	for _, spec := range specs {
+		if _, exists := opts[strings.ToUpper(spec.Name)]; spec.Required && !exists {
+			return nil, fmt.Errorf("%w: option %s is required", ErrSyntax, spec.Name)
+		}
+	}
+	return opts, nil
+}
+
+// findOption returns the spec declaring name in the given style, or nil
+func findOption(specs []OptionSpec, style OptionStyle, name string) *OptionSpec {
+	for i := range specs {
+		if specs[i].Style == style && strings.EqualFold(specs[i].Name, name) {
+			return &specs[i]
+		}
+	}
+	return nil
+}
+
+// Has reports whether option name was given
+func (o Options) Has(name string) bool {
+	_, exists := o[strings.ToUpper(name)]
+	return exists
+}
+
+// Value returns value i of option name, or nil if it was not given
+func (o Options) Value(name string, i int) interface{} {
+	values := o[strings.ToUpper(name)]
+	if i < 0 || i >= len(values) {
+		return nil
+	}
+	return values[i]
+}
+
+// String returns value i of option name as given, or "" if it was not
+// given or is not a string
+func (o Options) String(name string, i int) string {
+	s, _ := o.Value(name, i).(string)
+	return s
+}
+
+// Int returns value i of option name as declared ArgInt, or 0 if it was not
+func (o Options) Int(name string, i int) int64 {
+	n, _ := o.Value(name, i).(int64)
+	return n
+}
+
+// Float returns value i of option name as declared ArgFloat, or 0 if it was
+// not
+func (o Options) Float(name string, i int) float64 {
+	f, _ := o.Value(name, i).(float64)
+	return f
+}
+
+// Time returns value i of option name as declared ArgTimestamp, or the zero
+// time if it was not
+func (o Options) Time(name string, i int) time.Time {
+	t, _ := o.Value(name, i).(time.Time)
+	return t
+}
@@ -0,0 +1,41 @@
+package command
+
+// Router resolves the command serving a request from the command name as
+// sent and the arguments following it. It returns ErrCommandNotFound for
+// requests it does not serve, so that the server can try other extensions.
+type Router interface {
+	Resolve(name string, args []string) (*Command, error)
+}
+
+// RouterFunc adapts a function to the Router interface
+type RouterFunc func(name string, args []string) (*Command, error)
+
+// Resolve calls f(name, args)
+func (f RouterFunc) Resolve(name string, args []string) (*Command, error) {
+	return f(name, args)
+}
+
+// SetRouter replaces the way the extension resolves requests, for example
+// to route a family of dotted names by prefix or to pick a handler by the
+// content of the arguments. The router can fall back to the extension's
+// registered commands with GetCommand. A nil router restores the default
+// lookup by name.
+func (e *Extension) SetRouter(r Router) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.router = r
+}
+
+// Resolve implements Router. Requests go to the router set with SetRouter,
+// or else are looked up by name with GetCommand.
+func (e *Extension) Resolve(name string, args []string) (*Command, error) {
+	e.mu.RLock()
+	router := e.router
+	e.mu.RUnlock()
+
+	if router != nil {
+		return router.Resolve(name, args)
+	}
+	return e.GetCommand(name)
+}
@@ -0,0 +1,198 @@
+package command
+
+import (
+	"sync"
+	"time"
+)
+
+// Session holds the state of a single client connection that outlives
+// individual commands. It is safe for concurrent use.
+type Session struct {
+	id          int64
+	addr        string
+	created     time.Time
+	name        string
+	libName     string
+	libVersion  string
+	protocol    int
+	db          int
+	compression bool
+	monitoring  bool
+	subscribed  int
+	lastCmd     string
+	lastCmdAt   time.Time
+	onClose     []func()
+	mu          sync.RWMutex
+}
+
+// NewSession creates a new Session for a connection speaking RESP2
+func NewSession(id int64, addr string) *Session {
+	return &Session{
+		id:       id,
+		addr:     addr,
+		created:  time.Now(),
+		protocol: 2,
+	}
+}
+
+// ID returns the connection's unique id
+func (s *Session) ID() int64 {
+	return s.id
+}
+
+// Addr returns the client's remote address
+func (s *Session) Addr() string {
+	return s.addr
+}
+
+// Created returns the time the connection was accepted
+func (s *Session) Created() time.Time {
+	return s.created
+}
+
+// Name returns the client name, or "" if none was set
+func (s *Session) Name() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.name
+}
+
+// SetName sets the client name
+func (s *Session) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// Library returns the client library name and version reported with
+// CLIENT SETINFO, or "" for each that was not reported
+func (s *Session) Library() (name, version string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.libName, s.libVersion
+}
+
+// SetLibraryName records the client library name
+func (s *Session) SetLibraryName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.libName = name
+}
+
+// SetLibraryVersion records the client library version
+func (s *Session) SetLibraryVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.libVersion = version
+}
+
+// Protocol returns the negotiated RESP protocol version
+func (s *Session) Protocol() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.protocol
+}
+
+// SetProtocol sets the negotiated RESP protocol version
+func (s *Session) SetProtocol(version int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.protocol = version
+}
+
+// DB returns the index of the selected logical database
+func (s *Session) DB() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db
+}
+
+// SetDB selects a logical database
+func (s *Session) SetDB(index int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db = index
+}
+
+// Compression reports whether the client accepts compressed replies
+func (s *Session) Compression() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.compression
+}
+
+// SetCompression sets whether the client accepts compressed replies
+func (s *Session) SetCompression(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compression = enabled
+}
+
+// Monitoring reports whether the client has entered MONITOR mode
+func (s *Session) Monitoring() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.monitoring
+}
+
+// SetMonitoring sets whether the client receives the MONITOR command feed
+func (s *Session) SetMonitoring(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.monitoring = enabled
+}
+
+// Subscriptions returns the number of pub/sub channels the client is
+// subscribed to. While it is positive RESP2 clients accept push messages,
+// which they receive as arrays.
+func (s *Session) Subscriptions() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.subscribed
+}
+
+// SetSubscriptions sets the number of pub/sub channels the client is
+// subscribed to
+func (s *Session) SetSubscriptions(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribed = n
+}
+
+// LastCommand returns the name of the last command the client ran and
+// when it started, or "" if it has run none
+func (s *Session) LastCommand() (name string, at time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastCmd, s.lastCmdAt
+}
+
+// SetLastCommand records that the client started running the command
+// name. The server calls it for every command.
+func (s *Session) SetLastCommand(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastCmd = name
+	s.lastCmdAt = time.Now()
+}
+
+// OnClose registers a function to run when the connection ends, such as
+// dropping the client's subscriptions
+func (s *Session) OnClose(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onClose = append(s.onClose, fn)
+}
+
+// Close runs the functions registered with OnClose, in registration
+// order. The server calls it when the connection ends.
+func (s *Session) Close() {
+	s.mu.Lock()
+	fns := s.onClose
+	s.onClose = nil
+	s.mu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
@@ -0,0 +1,158 @@
+package glob
+
+// Match reports whether s matches the redis-style glob pattern.
+//
+// The pattern syntax follows redis's stringmatchlen:
+//
+//	h*llo       '*' matches any sequence of characters, including none
+//	h?llo       '?' matches any single character
+//	h[ae]llo    matches one character from the set
+//	h[^e]llo    matches one character not in the set
+//	h[a-b]llo   matches one character in the range (reversed ranges are allowed)
+//	h\*llo      matches the escaped character literally
+//
+// Unlike filepath.Match, '/' has no special meaning and a malformed
+// pattern never produces an error: an unterminated class is treated as
+// ending at the end of the pattern. As in redis, a pattern nesting more
+// than maxNesting stars separated by other characters matches nothing.
+func Match(pattern, s string) bool {
+	var skipLonger bool
+	return match(pattern, s, false, &skipLonger, 0)
+}
+
+// MatchNoCase is like Match but compares ASCII letters case-insensitively
+func MatchNoCase(pattern, s string) bool {
+	var skipLonger bool
+	return match(pattern, s, true, &skipLonger, 0)
+}
+
+// maxNesting bounds the recursion of match, one level per star
+const maxNesting = 1000
+
+// match is a byte-wise port of redis's stringmatchlen. skipLonger is set
+// once a star has tried every remaining suffix of s without a match: the
+// stars before it cannot do better by consuming more of s, so they give up
+// instead of retrying, which keeps patterns with many stars from taking
+// exponential time.
+func match(pattern, s string, nocase bool, skipLonger *bool, nesting int) bool {
+	if nesting > maxNesting {
+		return false
+	}
+	p, i := 0, 0
+
+	for p < len(pattern) && i < len(s) {
+		switch pattern[p] {
+		case '*':
+			// Collapse consecutive stars
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p++
+			}
+			if p+1 == len(pattern) {
+				return true
+			}
+			for ; i < len(s); i++ {
+				if match(pattern[p+1:], s[i:], nocase, skipLonger, nesting+1) {
+					return true
+				}
+				if *skipLonger {
+					return false
+				}
+			}
+			*skipLonger = true
+			return false
+
+		case '?':
+			i++
+
+		case '[':
+			p++
+			not := p < len(pattern) && pattern[p] == '^'
+			if not {
+				p++
+			}
+
+			matched := false
+			for p < len(pattern) && pattern[p] != ']' {
+				switch {
+				case pattern[p] == '\\' && p+1 < len(pattern):
+					p++
+					if equal(pattern[p], s[i], nocase) {
+						matched = true
+					}
+				case p+2 < len(pattern) && pattern[p+1] == '-':
+					lo, hi := pattern[p], pattern[p+2]
+					if lo > hi {
+						lo, hi = hi, lo
+					}
+					c := s[i]
+					if nocase {
+						lo, hi, c = lower(lo), lower(hi), lower(c)
+					}
+					if c >= lo && c <= hi {
+						matched = true
+					}
+					p += 2
+				default:
+					if equal(pattern[p], s[i], nocase) {
+						matched = true
+					}
+				}
+				p++
+			}
+			if p == len(pattern) {
+				// Unterminated class: step back so the outer increment
+				// lands on the end of the pattern
+				p--
+			}
+
+			if not {
+				matched = !matched
+			}
+			if !matched {
+				return false
+			}
+			i++
+
+		case '\\':
+			if p+1 < len(pattern) {
+				p++
+			}
+			if !equal(pattern[p], s[i], nocase) {
+				return false
+			}
+			i++
+
+		default:
+			if !equal(pattern[p], s[i], nocase) {
+				return false
+			}
+			i++
+		}
+
+		p++
+	}
+
+	// Trailing stars match the empty remainder
+	if i == len(s) {
+		for p < len(pattern) && pattern[p] == '*' {
+			p++
+		}
+	}
+	return p == len(pattern) && i == len(s)
+}
+
+// equal compares two bytes, optionally ignoring ASCII case
+func equal(a, b byte, nocase bool) bool {
+	if nocase {
+		return lower(a) == lower(b)
+	}
+	return a == b
+}
+
+// lower converts an ASCII letter to lower case
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
@@ -0,0 +1,116 @@
+package glob
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"hello", "hello", true},
+		{"hello", "hell", false},
+
+		{"*", "", true},
+		{"*", "anything", true},
+		{"h*llo", "hllo", true},
+		{"h*llo", "heeello", true},
+		{"h*llo", "hello!", false},
+		{"h**o", "hello", true},
+		{"*o*", "foo:bar", true},
+		{"*:*:*", "a:b", false},
+
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"???", "abc", true},
+		{"???", "ab", false},
+
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{"h[c-a]llo", "hbllo", true},
+		{"[0-9][0-9]", "42", true},
+
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"[^a-c]", "d", true},
+		{"[^a-c]", "b", false},
+
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`h\?llo`, "h?llo", true},
+		{`\[a]`, "[a]", true},
+		{`[\]]`, "]", true},
+		{`[\-]`, "-", true},
+		{`a\`, `a\`, true},
+
+		// An unterminated class ends at the end of the pattern
+		{"h[ab", "ha", true},
+		{"h[ab", "hb", true},
+		{"h[ab", "hc", false},
+		{"h[", "h", false},
+		{"h[^", "hx", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+// TestMatchPathological checks that patterns with many stars that nearly
+// match are rejected quickly rather than in time exponential in the number
+// of stars, see CVE-2022-36021
+func TestMatchPathological(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{strings.Repeat("*a", 12) + "b", strings.Repeat("a", 40), false},
+		{strings.Repeat("a*", 30) + "b", strings.Repeat("a", 100), false},
+		{strings.Repeat("*?", 20) + "x", strings.Repeat("y", 60), false},
+		{strings.Repeat("*a", 12) + "b", strings.Repeat("a", 40) + "b", true},
+		{strings.Repeat("*[a-c]", 12) + "d", strings.Repeat("b", 40), false},
+		{strings.Repeat("*a", 1000), strings.Repeat("a", 1000), true},
+		{strings.Repeat("*a", 1001), strings.Repeat("a", 1001), false},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		if got := Match(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+		if got := MatchNoCase(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("MatchNoCase(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Match(%q, %q) took %v", tt.pattern, tt.s, elapsed)
+		}
+	}
+}
+
+func TestMatchNoCase(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"HELLO", "hello", true},
+		{"h*LO", "HeLlo", true},
+		{"[A-C]x", "bX", true},
+		{"[^A-C]", "b", false},
+		{"h[E]llo", "hello", true},
+	}
+	for _, tt := range tests {
+		if got := MatchNoCase(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("MatchNoCase(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+	if Match("HELLO", "hello") {
+		t.Error(`Match("HELLO", "hello") = true, want false`)
+	}
+}
@@ -0,0 +1,246 @@
+package persist
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+)
+
+// FsyncPolicy controls how often the AOF is synced to disk
+type FsyncPolicy int
+
+const (
+	// FsyncEverySec syncs the log once per second in the background
+	FsyncEverySec FsyncPolicy = iota
+	// FsyncAlways syncs the log after every appended command
+	FsyncAlways
+	// FsyncNo leaves syncing to the operating system
+	FsyncNo
+)
+
+// Rewriter is implemented by extensions that can describe their current
+// state as a sequence of commands. It is used to compact the AOF.
+type Rewriter interface {
+	// RewriteAOF calls emit once per command needed to rebuild the state
+	RewriteAOF(emit func(args ...string) error) error
+}
+
+// AOF is an append-only log of write commands encoded as RESP arrays
+type AOF struct {
+	path   string
+	policy FsyncPolicy
+	file   *os.File
+	writer *resp.Writer
+	db     int // database of the last appended command, or -1 if unknown
+	dirty  bool
+	stop   chan struct{}
+	done   chan struct{}
+	mu     sync.Mutex
+}
+
+// OpenAOF opens or creates the log at path
+func OpenAOF(path string, policy FsyncPolicy) (*AOF, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AOF{
+		path:   path,
+		policy: policy,
+		file:   file,
+		writer: resp.NewWriter(file),
+		db:     -1,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if policy == FsyncEverySec {
+		go a.syncLoop()
+	} else {
+		close(a.done)
+	}
+	return a, nil
+}
+
+// Replay reads every command in the log from the beginning and passes it to fn
+func (a *AOF) Replay(fn func(args []string) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := resp.NewReader(f)
+	for {
+		name, raw, err := reader.ParseCommand()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("aof: %w", err)
+		}
+
+		args := make([]string, 1+len(raw))
+		args[0] = name
+		for i, arg := range raw {
+			args[i+1] = string(arg)
+		}
+
+		if err := fn(args); err != nil {
+			return err
+		}
+	}
+}
+
+// Append writes a command to the end of the log
+func (a *AOF) Append(args []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.append(args)
+}
+
+// AppendDB writes a command executed against logical database db. A SELECT
+// command is logged first whenever the database differs from that of the
+// previous command, so that replay executes it against the same database.
+func (a *AOF) AppendDB(db int, args []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.db != db {
+		if err := writeCommand(a.writer, []string{"SELECT", strconv.Itoa(db)}); err != nil {
+			return err
+		}
+		a.db = db
+	}
+	return a.append(args)
+}
+
+// append writes a command and syncs according to the policy. The caller
+// must hold a.mu.
+func (a *AOF) append(args []string) error {
+	if err := writeCommand(a.writer, args); err != nil {
+		return err
+	}
+
+	if a.policy == FsyncAlways {
+		return a.file.Sync()
+	}
+	a.dirty = true
+	return nil
+}
+
+// Rewrite replaces the log with the commands emitted by rw. The new log is
+// written to a temporary file and atomically renamed over the old one.
+// Appends block until the rewrite completes.
+func (a *AOF) Rewrite(rw Rewriter) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.path), filepath.Base(a.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	writer := resp.NewWriter(tmp)
+	err = rw.RewriteAOF(func(args ...string) error {
+		return writeCommand(writer, args)
+	})
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, a.path)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	file, err := os.OpenFile(a.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	a.file.Close()
+	a.file = file
+	a.writer = resp.NewWriter(file)
+	a.db = -1
+	a.dirty = false
+	return nil
+}
+
+// Size returns the current size of the log in bytes
+func (a *AOF) Size() (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info, err := a.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Close syncs and closes the log. It must be called at most once.
+func (a *AOF) Close() error {
+	close(a.stop)
+	<-a.done
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.file.Sync()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// syncLoop syncs dirty data once per second until the log is closed
+func (a *AOF) syncLoop() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.mu.Lock()
+			if a.dirty {
+				a.file.Sync()
+				a.dirty = false
+			}
+			a.mu.Unlock()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// writeCommand encodes a command as a RESP array of bulk strings
+func writeCommand(w *resp.Writer, args []string) error {
+	if err := w.WriteArray(len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := w.WriteBulkString(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
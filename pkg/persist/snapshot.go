@@ -0,0 +1,105 @@
+package persist
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Snapshotter is implemented by extensions whose state can be saved to and
+// restored from a point-in-time snapshot
+type Snapshotter interface {
+	// Save writes the current state to w
+	Save(w io.Writer) error
+	// Load replaces the current state with the one read from r
+	Load(r io.Reader) error
+}
+
+// jsonSnapshotter encodes a value as JSON
+type jsonSnapshotter struct {
+	v interface{}
+}
+
+// JSON returns a Snapshotter that encodes v as JSON. v must be a pointer so
+// that Load can decode into it. Callers are responsible for any locking.
+func JSON(v interface{}) Snapshotter {
+	return &jsonSnapshotter{v: v}
+}
+
+// Save encodes the value as JSON
+func (s *jsonSnapshotter) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s.v)
+}
+
+// Load decodes JSON into the value
+func (s *jsonSnapshotter) Load(r io.Reader) error {
+	return json.NewDecoder(r).Decode(s.v)
+}
+
+// gobSnapshotter encodes a value with encoding/gob
+type gobSnapshotter struct {
+	v interface{}
+}
+
+// Gob returns a Snapshotter that encodes v with encoding/gob. v must be a
+// pointer so that Load can decode into it. Callers are responsible for any
+// locking.
+func Gob(v interface{}) Snapshotter {
+	return &gobSnapshotter{v: v}
+}
+
+// Save encodes the value with gob
+func (s *gobSnapshotter) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(s.v)
+}
+
+// Load decodes gob data into the value
+func (s *gobSnapshotter) Load(r io.Reader) error {
+	return gob.NewDecoder(r).Decode(s.v)
+}
+
+// SaveFile writes a snapshot to path atomically. The snapshot is written to a
+// temporary file in the same directory, synced, and renamed over path, so a
+// crash mid-save never leaves a truncated snapshot behind.
+func SaveFile(path string, s Snapshotter) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if err := s.Save(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, path)
+}
+
+// LoadFile restores a snapshot from path. A missing file is not an error,
+// since there is nothing to restore on first start.
+func LoadFile(path string, s Snapshotter) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	return s.Load(f)
+}
@@ -0,0 +1,221 @@
+// Package pubsub implements redis-style publish/subscribe for extensions.
+// A Hub tracks the channels each client is subscribed to, sends the
+// confirmations redis clients wait for, and delivers published messages as
+// push frames.
+package pubsub
+
+import (
+	"sync"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+	"github.com/aakash-a-dev/Goluxis/pkg/store"
+)
+
+// subscriber is a client that has subscribed through the hub
+type subscriber struct {
+	conn     command.PushConn
+	session  *command.Session
+	channels map[string]struct{}
+}
+
+// Hub routes published messages to the clients subscribed to their
+// channel. It is safe for concurrent use.
+type Hub struct {
+	channels map[string]map[*subscriber]struct{}
+	clients  map[*command.Session]*subscriber
+	mu       sync.RWMutex
+}
+
+// NewHub creates an empty Hub
+func NewHub() *Hub {
+	return &Hub{
+		channels: make(map[string]map[*subscriber]struct{}),
+		clients:  make(map[*command.Session]*subscriber),
+	}
+}
+
+// Commands returns SUBSCRIBE, UNSUBSCRIBE and PUBLISH commands served by
+// the hub. Clients expect these names, so they should be added to an
+// extension that is not namespaced.
+func (h *Hub) Commands() []*command.Command {
+	subscribe := command.New("SUBSCRIBE")
+	subscribe.Description = "Listen for messages published to channels"
+	subscribe.MinArgs = 1
+	subscribe.Handler = func(ctx *command.Context) error {
+		return h.Subscribe(ctx, ctx.Args[1:]...)
+	}
+
+	unsubscribe := command.New("UNSUBSCRIBE")
+	unsubscribe.Description = "Stop listening for messages published to channels"
+	unsubscribe.Handler = func(ctx *command.Context) error {
+		return h.Unsubscribe(ctx, ctx.Args[1:]...)
+	}
+
+	publish := command.New("PUBLISH")
+	publish.Description = "Post a message to a channel"
+	publish.MinArgs = 2
+	publish.MaxArgs = 2
+	publish.Handler = func(ctx *command.Context) error {
+		return ctx.ReplyInt(int64(h.Publish(ctx.Args[1], ctx.Args[2])))
+	}
+
+	return []*command.Command{subscribe, unsubscribe, publish}
+}
+
+// Subscribe subscribes the client to channels and, as redis does, sends
+// one ["subscribe", channel, count] confirmation per channel, where count
+// is the number of channels the client is subscribed to after that one.
+// It is the whole reply of a SUBSCRIBE command: the handler must not
+// reply otherwise. Connections that cannot receive push frames are
+// refused with resp.ErrPushUnsupported.
+func (h *Hub) Subscribe(ctx *command.Context, channels ...string) error {
+	conn, ok := ctx.Conn.(command.PushConn)
+	if !ok {
+		return resp.ErrPushUnsupported
+	}
+
+	h.mu.Lock()
+	sub, exists := h.clients[ctx.Session]
+	if !exists {
+		sub = &subscriber{
+			conn:     conn,
+			session:  ctx.Session,
+			channels: make(map[string]struct{}),
+		}
+		h.clients[ctx.Session] = sub
+		ctx.Session.OnClose(func() { h.remove(sub) })
+	}
+
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		if _, exists := sub.channels[channel]; !exists {
+			sub.channels[channel] = struct{}{}
+			if h.channels[channel] == nil {
+				h.channels[channel] = make(map[*subscriber]struct{})
+			}
+			h.channels[channel][sub] = struct{}{}
+		}
+		counts[i] = len(sub.channels)
+	}
+	ctx.Session.SetSubscriptions(len(sub.channels))
+	h.mu.Unlock()
+
+	for i, channel := range channels {
+		if err := confirm(ctx, "subscribe", channel, counts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unsubscribe unsubscribes the client from channels, or from every channel
+// if none are given, sending one ["unsubscribe", channel, count]
+// confirmation per channel. A client subscribed to nothing that
+// unsubscribes from every channel gets a single confirmation with a null
+// channel and a count of 0. It is the whole reply of an UNSUBSCRIBE
+// command.
+func (h *Hub) Unsubscribe(ctx *command.Context, channels ...string) error {
+	h.mu.Lock()
+	sub := h.clients[ctx.Session]
+	if len(channels) == 0 && sub != nil {
+		channels = store.SortedKeys(sub.channels)
+	}
+
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		if sub != nil {
+			h.unsubscribe(sub, channel)
+			counts[i] = len(sub.channels)
+		}
+	}
+	if sub != nil {
+		ctx.Session.SetSubscriptions(len(sub.channels))
+	}
+	h.mu.Unlock()
+
+	if len(channels) == 0 {
+		return confirm(ctx, "unsubscribe", nil, 0)
+	}
+	for i, channel := range channels {
+		if err := confirm(ctx, "unsubscribe", channel, counts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset silently unsubscribes the client from every channel. It can be
+// passed to Server.OnReset so that RESET leaves pub/sub mode.
+func (h *Hub) Reset(ctx *command.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if sub, exists := h.clients[ctx.Session]; exists {
+		h.unsubscribeAll(sub)
+	}
+}
+
+// Publish sends message to every client subscribed to channel as a
+// ["message", channel, message] push and returns the number of clients
+// it was sent to. It does not wait for slow clients, whose messages are
+// queued up to the server's OutputBufferLimit. Clients that cannot be
+// written to, or were disconnected for exceeding that limit, are
+// unsubscribed.
+func (h *Hub) Publish(channel, message string) int {
+	h.mu.RLock()
+	subs := make([]*subscriber, 0, len(h.channels[channel]))
+	for sub := range h.channels[channel] {
+		subs = append(subs, sub)
+	}
+	h.mu.RUnlock()
+
+	sent := 0
+	for _, sub := range subs {
+		if err := sub.conn.WritePush("message", channel, message); err != nil {
+			h.remove(sub)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// remove forgets a client whose connection ended or failed
+func (h *Hub) remove(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.unsubscribeAll(sub)
+	if h.clients[sub.session] == sub {
+		delete(h.clients, sub.session)
+	}
+}
+
+// unsubscribeAll removes a client from every channel. The client stays
+// known to the hub, so its close hook is not registered again if it
+// subscribes later. The caller must hold h.mu.
+func (h *Hub) unsubscribeAll(sub *subscriber) {
+	for channel := range sub.channels {
+		h.unsubscribe(sub, channel)
+	}
+	sub.session.SetSubscriptions(0)
+}
+
+// unsubscribe removes a client from one channel. The caller must hold h.mu.
+func (h *Hub) unsubscribe(sub *subscriber, channel string) {
+	delete(sub.channels, channel)
+	delete(h.channels[channel], sub)
+	if len(h.channels[channel]) == 0 {
+		delete(h.channels, channel)
+	}
+}
+
+// confirm sends a subscription confirmation. RESP3 clients receive it as a
+// push frame, like messages; RESP2 clients as an array reply.
+func confirm(ctx *command.Context, kind string, channel interface{}, count int) error {
+	if ctx.Session.Protocol() >= resp.RESP3 {
+		return ctx.Push(kind, channel, int64(count))
+	}
+	return ctx.ReplyValue([]interface{}{kind, channel, int64(count)})
+}
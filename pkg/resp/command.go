@@ -0,0 +1,138 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInvalidCommand is returned by ParseCommand for a well-formed frame
+// that is not a command. The frame has been consumed, so the reader is
+// still in sync and the next command can be read.
+var ErrInvalidCommand = errors.New("invalid command")
+
+// ParseCommand reads a command: an array of bulk strings whose first
+// element is the command name. The arguments following the name are
+// returned as raw bytes, without going through interface values.
+// Attribute frames preceding the command are available from Attributes.
+//
+// Other frames, empty arrays and arrays holding anything but bulk strings
+// fail with an error wrapping ErrInvalidCommand; other errors mean the
+// stream is unusable. A client disconnecting between commands gives io.EOF,
+// and one disconnecting partway through a frame ErrTruncated.
+func (r *Reader) ParseCommand() (name string, args [][]byte, err error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return "", nil, err
+	}
+	name, args, err = r.parseCommand(typ)
+	return name, args, truncated(err)
+}
+
+// parseCommand reads the rest of a command after its first type byte
+func (r *Reader) parseCommand(typ byte) (name string, args [][]byte, err error) {
+	for err == nil && typ == Attribute {
+		var attrs map[string]interface{}
+		if attrs, err = r.readMap(); err != nil {
+			return "", nil, err
+		}
+		r.attrs = attrs
+		typ, err = r.ReadByte()
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if typ != Array {
+		if err := r.skip(); err != nil {
+			return "", nil, err
+		}
+		return "", nil, fmt.Errorf("%w: expected an array, got %q", ErrInvalidCommand, typ)
+	}
+
+	line, err := r.readLine()
+	if err != nil {
+		return "", nil, err
+	}
+	if line == StreamedLength {
+		if _, err := r.readStreamedArray(); err != nil {
+			return "", nil, err
+		}
+		return "", nil, fmt.Errorf("%w: streamed arrays are not accepted", ErrInvalidCommand)
+	}
+	length, err := r.parseAggregateLength(line)
+	if err != nil {
+		return "", nil, err
+	}
+	if length < 1 {
+		return "", nil, fmt.Errorf("%w: empty command", ErrInvalidCommand)
+	}
+
+	// Read every element even after a bad one so the reader stays in sync
+	items := make([][]byte, 0, min(length, preallocLength))
+	var invalid error
+	for i := int64(0); i < length; i++ {
+		typ, err := r.ReadByte()
+		if err != nil {
+			return "", nil, err
+		}
+		if typ != BulkString {
+			if err := r.skip(); err != nil {
+				return "", nil, err
+			}
+			if invalid == nil {
+				invalid = fmt.Errorf("%w: expected a bulk string, got %q", ErrInvalidCommand, typ)
+			}
+			continue
+		}
+		item, err := r.readBulkBytes()
+		if err != nil {
+			return "", nil, err
+		}
+		if item == nil && invalid == nil {
+			invalid = fmt.Errorf("%w: null bulk string", ErrInvalidCommand)
+		}
+		items = append(items, item)
+	}
+	if invalid != nil {
+		return "", nil, invalid
+	}
+	return string(items[0]), items[1:], nil
+}
+
+// skip consumes the rest of a frame whose type byte has just been read
+func (r *Reader) skip() error {
+	if err := r.UnreadByte(); err != nil {
+		return err
+	}
+	_, err := r.ReadObject()
+	return err
+}
+
+// readBulkBytes reads the rest of a bulk string after its type byte. A
+// null bulk string is returned as nil; an empty one as a non-nil slice.
+func (r *Reader) readBulkBytes() ([]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	length, err := parseLength(line)
+	if err != nil {
+		return nil, err
+	}
+	if length == -1 {
+		return nil, nil
+	}
+	if length > r.maxBulk {
+		return nil, fmt.Errorf("%w: invalid bulk length %d", ErrInvalidFormat, length)
+	}
+
+	buf := make([]byte, length+2) // +2 for CRLF
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	if buf[length] != '\r' || buf[length+1] != '\n' {
+		return nil, ErrInvalidFormat
+	}
+	return buf[:length:length], nil
+}
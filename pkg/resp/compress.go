@@ -0,0 +1,51 @@
+package resp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CompressedFormat is the verbatim string format marking a gzip payload
+const CompressedFormat = "gzp"
+
+// WriteCompressed gzips p and writes it as a verbatim string in
+// CompressedFormat. Readers with SetDecompress enabled decompress such
+// strings transparently.
+// Compression needs the verbatim type, so RESP2 writers send p as is.
+func (w *Writer) WriteCompressed(p []byte) error {
+	if w.proto < RESP3 {
+		return w.WriteBulkString(string(p))
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(p); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return w.WriteVerbatim(CompressedFormat, buf.String())
+}
+
+// decompress gunzips a compressed verbatim payload, failing if it inflates
+// to more than max bytes
+func decompress(s string, max int64) (string, error) {
+	zr, err := gzip.NewReader(strings.NewReader(s))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(io.LimitReader(zr, max+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > max {
+		return "", fmt.Errorf("%w: compressed payload inflates past %d bytes", ErrInvalidFormat, max)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,73 @@
+package resp
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// byteCount counts bytes while enabled. It is safe for concurrent use, so
+// counts can be read while a connection is being served.
+type byteCount struct {
+	enabled atomic.Bool
+	n       atomic.Int64
+}
+
+// add counts n bytes if counting is enabled
+func (c *byteCount) add(n int) {
+	if n > 0 && c.enabled.Load() {
+		c.n.Add(int64(n))
+	}
+}
+
+// countingReader counts the bytes read from rd
+type countingReader struct {
+	rd    io.Reader
+	count *byteCount
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.rd.Read(p)
+	r.count.add(n)
+	return n, err
+}
+
+// CountBytes turns counting of the bytes read from the underlying reader
+// on or off. Counting is off by default. A *bufio.Reader passed to
+// NewReader is read as is, so its bytes cannot be counted.
+func (r *Reader) CountBytes(enable bool) {
+	r.read.enabled.Store(enable)
+}
+
+// BytesRead returns the number of bytes read from the underlying reader
+// while counting was enabled. Bytes buffered ahead of the value being
+// parsed are included. It may be called from any goroutine.
+func (r *Reader) BytesRead() int64 {
+	return r.read.n.Load()
+}
+
+// CountBytes turns counting of the bytes written on or off. Counting is
+// off by default.
+func (w *Writer) CountBytes(enable bool) {
+	w.written.enabled.Store(enable)
+}
+
+// BytesWritten returns the number of bytes written while counting was
+// enabled, including bytes still buffered. It may be called from any
+// goroutine.
+func (w *Writer) BytesWritten() int64 {
+	return w.written.n.Load()
+}
+
+// Write writes p to the buffer, counting its bytes
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.written.add(n)
+	return n, err
+}
+
+// WriteString writes s to the buffer, counting its bytes
+func (w *Writer) WriteString(s string) (int, error) {
+	n, err := w.Writer.WriteString(s)
+	w.written.add(n)
+	return n, err
+}
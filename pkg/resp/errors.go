@@ -0,0 +1,50 @@
+package resp
+
+import "fmt"
+
+// ErrorReply is an error reply with one of redis's error codes, the first
+// word of the message, which clients use to tell classes of errors apart.
+// WriteError sends it as "-CODE message".
+type ErrorReply struct {
+	Code    string
+	Message string
+}
+
+func (e *ErrorReply) Error() string {
+	return e.Code + " " + e.Message
+}
+
+// Is reports whether target is an ErrorReply with the same code and
+// message, so that errors.Is(err, resp.ErrSyntax()) matches
+func (e *ErrorReply) Is(target error) bool {
+	t, ok := target.(*ErrorReply)
+	return ok && t.Code == e.Code && t.Message == e.Message
+}
+
+// ErrWrongType is redis's error for a command run against a key holding
+// another type of value
+func ErrWrongType() error {
+	return &ErrorReply{Code: "WRONGTYPE", Message: "Operation against a key holding the wrong kind of value"}
+}
+
+// ErrNoAuth is redis's error for a command sent before authenticating
+func ErrNoAuth() error {
+	return &ErrorReply{Code: "NOAUTH", Message: "Authentication required."}
+}
+
+// ErrNoPerm is redis's error for a command the client is not allowed to
+// run
+func ErrNoPerm(cmd string) error {
+	return &ErrorReply{Code: "NOPERM", Message: fmt.Sprintf("this user has no permissions to run the '%s' command", cmd)}
+}
+
+// ErrOutOfRange is redis's error for an argument that is not an integer or
+// does not fit the range allowed
+func ErrOutOfRange() error {
+	return &ErrorReply{Code: "ERR", Message: "value is not an integer or out of range"}
+}
+
+// ErrSyntax is redis's error for malformed arguments
+func ErrSyntax() error {
+	return &ErrorReply{Code: "ERR", Message: "syntax error"}
+}
@@ -0,0 +1,89 @@
+package resp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// WriteJSON writes the JSON document data as the equivalent RESP value
+// rather than as a string: objects become maps, arrays arrays, strings bulk
+// strings, true and false booleans and null a null. Integers are written as
+// integers, or big numbers past the int64 range, and other numbers as
+// doubles. Object keys are written in sorted order. RESP2 clients receive
+// maps as flat arrays of keys and values, booleans as 1 and 0, and doubles
+// and big numbers as bulk strings. Inside a stream the document is one
+// element.
+func (w *Writer) WriteJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if dec.More() {
+		return errors.New("invalid JSON: data after the top-level value")
+	}
+
+	if w.stream != nil {
+		w.stream.count++
+	}
+	return w.writeJSON(v)
+}
+
+// writeJSON writes a value decoded by WriteJSON
+func (w *Writer) writeJSON(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		return w.WriteNull()
+	case bool:
+		return w.WriteBoolean(v)
+	case string:
+		return w.WriteBulkString(v)
+	case json.Number:
+		return w.writeJSONNumber(v)
+	case []interface{}:
+		if err := w.WriteArray(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := w.writeJSON(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		if err := w.WriteMap(len(v)); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(v) {
+			if err := w.WriteBulkString(key); err != nil {
+				return err
+			}
+			if err := w.writeJSON(v[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported JSON value type %T", v)
+	}
+}
+
+// writeJSONNumber writes a JSON number as an integer, a big number or a
+// double, whichever holds it exactly
+func (w *Writer) writeJSONNumber(n json.Number) error {
+	if i, err := n.Int64(); err == nil {
+		return w.WriteInteger(i)
+	}
+	if b, ok := new(big.Int).SetString(n.String(), 10); ok {
+		return w.WriteBigNumber(b)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("invalid JSON number %s: %w", n, err)
+	}
+	return w.WriteDouble(f)
+}
@@ -0,0 +1,40 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// ErrTrailingData is returned by Unmarshal for data holding more than one
+// value
+var ErrTrailingData = errors.New("trailing data after RESP value")
+
+// Marshal encodes v as it would be sent to a client speaking protocol
+// version proto, for caching replies or comparing them with golden files.
+// It accepts the types WriteValue does.
+func Marshal(v interface{}, proto int) ([]byte, error) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetProtocol(proto)
+	if err := w.WriteValue(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes data holding exactly one RESP value, as ReadObject
+// does. Error replies are returned as values of type error, not as the
+// error result. Incomplete data fails with ErrTruncated and data left after
+// the value with ErrTrailingData.
+func Unmarshal(data []byte) (interface{}, error) {
+	r := NewReader(bytes.NewReader(data))
+	v, err := r.ReadObject()
+	if err != nil {
+		return nil, truncated(err)
+	}
+	if _, err := r.Peek(1); err != io.EOF {
+		return nil, ErrTrailingData
+	}
+	return v, nil
+}
@@ -5,8 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"strconv"
-	"strings"
 )
 
 const (
@@ -16,30 +17,209 @@ const (
 	Integer      = ':'
 	BulkString   = '$'
 	Array        = '*'
+
+	// RESP3 type bytes
+	Null           = '_'
+	Map            = '%'
+	Attribute      = '|'
+	Double         = ','
+	Boolean        = '#'
+	BigNumber      = '('
+	VerbatimString = '='
+	Push           = '>'
+	Set            = '~'
+	StreamEnd      = '.'
+)
+
+const (
+	// Protocol versions
+	RESP2 = 2
+	RESP3 = 3
 )
 
+// DefaultMaxLineLength is the default limit on the length of a single
+// protocol line, matching redis's proto-inline-max-size
+const DefaultMaxLineLength = 64 * 1024
+
+// DefaultMaxBulkLength is the default limit on the length of a bulk
+// string, matching redis's proto-max-bulk-len
+const DefaultMaxBulkLength = 512 << 20
+
+// DefaultMaxAggregateLength is the default limit on the number of elements
+// of an array, set, push or map, matching redis's limit on multibulk
+// lengths
+const DefaultMaxAggregateLength = 1024 * 1024
+
+// preallocLength bounds the elements allocated for an aggregate before
+// they arrive, so that a peer announcing a long one cannot make the reader
+// allocate more than it sends
+const preallocLength = 1024
+
 var (
 	ErrInvalidFormat = errors.New("invalid RESP format")
-	CRLF             = "\r\n"
+	ErrLineTooLong   = errors.New("RESP line too long")
+	// ErrPushUnsupported is returned when writing a push frame to a RESP2
+	// client, which would mistake it for the reply to its next command
+	ErrPushUnsupported = errors.New("push frames require RESP3")
+	// ErrReplyTooLarge is returned by writes that would take the current
+	// reply past the limit set with SetReplyLimit
+	ErrReplyTooLarge = errors.New("reply too large")
+	// ErrTruncated is returned when the stream ends partway through a
+	// frame. A stream ending between frames gives io.EOF instead.
+	ErrTruncated = fmt.Errorf("%w: stream ended mid-frame", ErrInvalidFormat)
+	CRLF         = "\r\n"
 )
 
+// Verbatim is a RESP3 verbatim string for WriteValue: text tagged with a
+// three character format such as "txt" for plain text or "mkd" for markdown
+type Verbatim struct {
+	Format string
+	Text   string
+}
+
+// String returns the text without its format
+func (v Verbatim) String() string {
+	return v.Text
+}
+
+// SetValue is a RESP3 set as decoded by ReadObject: an unordered
+// collection of distinct elements. WriteValue writes it as a set.
+type SetValue []interface{}
+
+// PushMessage is a RESP3 push frame: an out-of-band message sent by the
+// server, such as an invalidation, rather than a reply to a command
+type PushMessage struct {
+	Kind string
+	Data []interface{}
+}
+
 // Reader implements RESP protocol reading
 type Reader struct {
 	*bufio.Reader
+	attrs   map[string]interface{}
+	format  string // of the last verbatim string read
+	maxLine int
+	maxBulk int64
+	maxAgg  int64
+	inflate bool // decompress verbatim strings in CompressedFormat
+	types   map[byte]func(*Reader) (interface{}, error)
+	read    *byteCount
 }
 
-// NewReader creates a new RESP reader
+// NewReader creates a new RESP reader. A *bufio.Reader is used directly,
+// whatever its buffer size, so that bytes it has already buffered are not
+// lost behind a second buffer.
 func NewReader(rd io.Reader) *Reader {
-	return &Reader{bufio.NewReader(rd)}
+	count := &byteCount{}
+	br, ok := rd.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(countingReader{rd: rd, count: count})
+	}
+	return &Reader{
+		Reader:  br,
+		maxLine: DefaultMaxLineLength,
+		maxBulk: DefaultMaxBulkLength,
+		maxAgg:  DefaultMaxAggregateLength,
+		read:    count,
+	}
+}
+
+// SetMaxLineLength limits the length, including CRLF, of simple strings,
+// errors, integers and the length lines of bulk strings and aggregates.
+// A non-positive n restores DefaultMaxLineLength.
+func (r *Reader) SetMaxLineLength(n int) {
+	if n <= 0 {
+		n = DefaultMaxLineLength
+	}
+	r.maxLine = n
+}
+
+// SetMaxBulkLength limits the length of bulk strings, so that a client
+// cannot make the reader allocate an arbitrary amount of memory by
+// announcing a huge one. A non-positive n restores DefaultMaxBulkLength.
+func (r *Reader) SetMaxBulkLength(n int64) {
+	if n <= 0 {
+		n = DefaultMaxBulkLength
+	}
+	r.maxBulk = n
+}
+
+// SetDecompress sets whether verbatim strings in CompressedFormat are
+// decompressed as they are read, as a client that negotiated compression
+// needs. Decompressed payloads are limited like bulk strings. It is off by
+// default, so that a server never inflates what its clients send.
+func (r *Reader) SetDecompress(enabled bool) {
+	r.inflate = enabled
+}
+
+// SetMaxAggregateLength limits the number of elements of arrays, sets,
+// pushes and maps, counting a map entry as one element. A non-positive n
+// restores DefaultMaxAggregateLength.
+func (r *Reader) SetMaxAggregateLength(n int64) {
+	if n <= 0 {
+		n = DefaultMaxAggregateLength
+	}
+	r.maxAgg = n
+}
+
+// RegisterType makes ReadObject decode values of type byte b with fn, for
+// experimenting with types the protocol does not define. fn is called after
+// the type byte has been consumed and must read the rest of the value,
+// using ReadObject for nested values and the embedded bufio.Reader for raw
+// bytes. Type bytes the reader handles natively always use the native
+// decoding, and ReadReply does not consult registered types.
+func (r *Reader) RegisterType(b byte, fn func(*Reader) (interface{}, error)) {
+	if r.types == nil {
+		r.types = make(map[byte]func(*Reader) (interface{}, error))
+	}
+	r.types[b] = fn
+}
+
+// Attributes returns the RESP3 attributes that preceded the most recently
+// read value carrying any, or nil if none have been read. ReadObject skips
+// attribute frames and returns the value they annotate.
+func (r *Reader) Attributes() map[string]interface{} {
+	return r.attrs
 }
 
-// ReadObject reads a RESP object from the reader
+// TakeAttributes is like Attributes but also clears them, so that a later
+// call only sees attributes read after this one
+func (r *Reader) TakeAttributes() map[string]interface{} {
+	attrs := r.attrs
+	r.attrs = nil
+	return attrs
+}
+
+// VerbatimFormat returns the format, such as "txt" or "mkd", of the most
+// recently read verbatim string, or "" if none has been read. ReadObject
+// returns verbatim strings as plain strings.
+func (r *Reader) VerbatimFormat() string {
+	return r.format
+}
+
+// ReadObject reads a RESP object from the reader. It fails with io.EOF if
+// the stream ends before the object starts and with ErrTruncated if it ends
+// partway through.
 func (r *Reader) ReadObject() (interface{}, error) {
 	typ, err := r.ReadByte()
 	if err != nil {
 		return nil, err
 	}
+	v, err := r.readObject(typ)
+	return v, truncated(err)
+}
+
+// truncated turns the end of the stream, reached after the first byte of
+// a frame, into ErrTruncated
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return err
+}
 
+// readObject reads the rest of an object after its type byte
+func (r *Reader) readObject(typ byte) (interface{}, error) {
 	switch typ {
 	case SimpleString:
 		return r.readLine()
@@ -55,21 +235,73 @@ func (r *Reader) ReadObject() (interface{}, error) {
 		return r.readBulkString()
 	case Array:
 		return r.readArray()
+	case Null:
+		_, err := r.readLine()
+		return nil, err
+	case Map:
+		return r.readMap()
+	case Double:
+		return r.readDouble()
+	case Boolean:
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line != "t" && line != "f" {
+			return nil, ErrInvalidFormat
+		}
+		return line == "t", nil
+	case Set:
+		elements, err := r.readArray()
+		if err != nil {
+			return nil, err
+		}
+		return SetValue(elements), nil
+	case BigNumber:
+		return r.readBigNumber()
+	case Attribute:
+		attrs, err := r.readMap()
+		if err != nil {
+			return nil, err
+		}
+		r.attrs = attrs
+		return r.ReadObject()
+	case VerbatimString:
+		return r.readVerbatimString()
+	case Push:
+		return r.readPush()
+	case StreamEnd:
+		return nil, ErrUnexpectedEnd
 	default:
+		if fn, ok := r.types[typ]; ok {
+			return fn(r)
+		}
 		return nil, fmt.Errorf("unknown RESP type byte: %c", typ)
 	}
 }
 
-// readLine reads a line terminated by CRLF
+// readLine reads a line terminated by CRLF. Lines longer than the
+// reader's maximum line length fail with ErrLineTooLong.
 func (r *Reader) readLine() (string, error) {
-	line, err := r.ReadString('\n')
-	if err != nil {
-		return "", err
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > r.maxLine {
+			return "", ErrLineTooLong
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return "", err
+		}
 	}
+
 	if len(line) < 2 || line[len(line)-2] != '\r' {
 		return "", ErrInvalidFormat
 	}
-	return line[:len(line)-2], nil
+	return string(line[:len(line)-2]), nil
 }
 
 // readInteger reads a RESP integer
@@ -78,36 +310,63 @@ func (r *Reader) readInteger() (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return strconv.ParseInt(line, 10, 64)
+	return parseInteger(line)
 }
 
-// readBulkString reads a RESP bulk string
-func (r *Reader) readBulkString() (string, error) {
-	length, err := r.readInteger()
+// parseInteger parses an integer or length line. Empty, padded or otherwise
+// malformed lines are protocol errors wrapping ErrInvalidFormat.
+func parseInteger(line string) (int64, error) {
+	n, err := strconv.ParseInt(line, 10, 64)
 	if err != nil {
-		return "", err
+		return 0, fmt.Errorf("%w: invalid integer %q", ErrInvalidFormat, line)
 	}
+	return n, nil
+}
 
-	if length == -1 {
-		return "", nil // null bulk string
+// parseLength parses the length line of a bulk string or aggregate. -1
+// denotes null; any other negative length is a protocol error.
+func parseLength(line string) (int64, error) {
+	n, err := parseInteger(line)
+	if err != nil {
+		return 0, err
+	}
+	if n < -1 {
+		return 0, fmt.Errorf("%w: invalid length %d", ErrInvalidFormat, n)
 	}
+	return n, nil
+}
 
-	buf := make([]byte, length+2) // +2 for CRLF
-	_, err = io.ReadFull(r, buf)
+// parseAggregateLength parses the length line of an aggregate, rejecting
+// lengths past the limit set with SetMaxAggregateLength
+func (r *Reader) parseAggregateLength(line string) (int64, error) {
+	n, err := parseLength(line)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
-
-	if !strings.HasSuffix(string(buf), CRLF) {
-		return "", ErrInvalidFormat
+	if n > r.maxAgg {
+		return 0, fmt.Errorf("%w: invalid aggregate length %d", ErrInvalidFormat, n)
 	}
+	return n, nil
+}
 
-	return string(buf[:length]), nil
+// readBulkString reads a RESP bulk string
+func (r *Reader) readBulkString() (string, error) {
+	b, err := r.readBulkBytes()
+	return string(b), err
 }
 
 // readArray reads a RESP array
 func (r *Reader) readArray() ([]interface{}, error) {
-	length, err := r.readInteger()
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	if line == StreamedLength {
+		return r.readStreamedArray()
+	}
+
+	length, err := r.parseAggregateLength(line)
 	if err != nil {
 		return nil, err
 	}
@@ -116,25 +375,179 @@ func (r *Reader) readArray() ([]interface{}, error) {
 		return nil, nil // null array
 	}
 
-	array := make([]interface{}, length)
-	for i := range array {
-		array[i], err = r.ReadObject()
+	array := make([]interface{}, 0, min(length, preallocLength))
+	for i := int64(0); i < length; i++ {
+		item, err := r.ReadObject()
 		if err != nil {
 			return nil, err
 		}
+		array = append(array, item)
 	}
 
 	return array, nil
 }
 
+// readDouble reads a RESP3 double, including the inf, -inf and nan forms
+func (r *Reader) readDouble() (float64, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return 0, err
+	}
+
+	switch line {
+	case "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(line, 64)
+}
+
+// readBigNumber reads a RESP3 big number
+func (r *Reader) readBigNumber() (*big.Int, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	n, ok := new(big.Int).SetString(line, 10)
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+	return n, nil
+}
+
+// readMap reads a RESP3 map into a map keyed by the string form of each key
+func (r *Reader) readMap() (map[string]interface{}, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	length, err := r.parseAggregateLength(line)
+	if err != nil {
+		return nil, err
+	}
+	if length == -1 {
+		return nil, nil // null map
+	}
+
+	m := make(map[string]interface{}, min(length, preallocLength))
+	for i := int64(0); i < length; i++ {
+		key, err := r.ReadObject()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.ReadObject()
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(key)] = value
+	}
+	return m, nil
+}
+
+// readPush reads a RESP3 push frame. The first element is its kind.
+func (r *Reader) readPush() (PushMessage, error) {
+	items, err := r.readArray()
+	if err != nil {
+		return PushMessage{}, err
+	}
+	if len(items) == 0 {
+		return PushMessage{}, ErrInvalidFormat
+	}
+	return PushMessage{Kind: fmt.Sprint(items[0]), Data: items[1:]}, nil
+}
+
+// readVerbatimString reads a RESP3 verbatim string and returns its content
+// without the format prefix, which is kept for VerbatimFormat. Compressed
+// payloads are decompressed if SetDecompress is enabled.
+func (r *Reader) readVerbatimString() (string, error) {
+	s, err := r.readBulkString()
+	if err != nil {
+		return "", err
+	}
+	if len(s) < 4 || s[3] != ':' {
+		return "", ErrInvalidFormat
+	}
+
+	format, content := s[:3], s[4:]
+	r.format = format
+	if format == CompressedFormat && r.inflate {
+		return decompress(content, r.maxBulk)
+	}
+	return content, nil
+}
+
 // Writer implements RESP protocol writing
 type Writer struct {
 	*bufio.Writer
+	proto  int
+	stream *stream
+
+	// Size of the current reply, see SetReplyLimit
+	limit    int64
+	size     int64
+	sent     bool
+	tooLarge bool
+
+	written byteCount
 }
 
-// NewWriter creates a new RESP writer
+// NewWriter creates a new RESP writer speaking RESP2. A *bufio.Writer is
+// used directly rather than wrapped in another buffer.
 func NewWriter(w io.Writer) *Writer {
-	return &Writer{bufio.NewWriter(w)}
+	bw, ok := w.(*bufio.Writer)
+	if !ok {
+		bw = bufio.NewWriter(w)
+	}
+	return &Writer{Writer: bw, proto: RESP2}
+}
+
+// SetProtocol sets the protocol version used for types that differ
+// between RESP2 and RESP3
+func (w *Writer) SetProtocol(version int) {
+	w.proto = version
+}
+
+// Protocol returns the protocol version in use
+func (w *Writer) Protocol() int {
+	return w.proto
+}
+
+// SetReplyLimit caps the number of bytes written for the current reply.
+// Once a write would exceed it, that write and every later one fail with
+// ErrReplyTooLarge until ResetReply. Zero or less removes the limit.
+func (w *Writer) SetReplyLimit(n int64) {
+	w.limit = n
+}
+
+// ResetReply starts a new reply, clearing its size and discarding any
+// stream still buffered for a RESP2 client
+func (w *Writer) ResetReply() {
+	w.size = 0
+	w.sent = false
+	w.tooLarge = false
+	w.stream = nil
+}
+
+// ReplySize returns the number of bytes written for the current reply,
+// including stream elements buffered for a RESP2 client
+func (w *Writer) ReplySize() int64 {
+	return w.size
+}
+
+// ReplySent reports whether any part of the current reply has reached the
+// underlying writer
+func (w *Writer) ReplySent() bool {
+	return w.sent
+}
+
+// ReplyTooLarge reports whether a write of the current reply failed with
+// ErrReplyTooLarge
+func (w *Writer) ReplyTooLarge() bool {
+	return w.tooLarge
 }
 
 // WriteSimpleString writes a RESP simple string
@@ -152,14 +565,40 @@ func (w *Writer) WriteInteger(i int64) error {
 	return w.writeString(fmt.Sprintf("%c%d%s", Integer, i, CRLF))
 }
 
-// WriteBulkString writes a RESP bulk string
+// WriteBoolean writes a RESP3 boolean, `#t` or `#f`. RESP2 has no boolean
+// type, so it is sent as the integer 1 or 0.
+func (w *Writer) WriteBoolean(b bool) error {
+	if w.proto < RESP3 {
+		if b {
+			return w.WriteInteger(1)
+		}
+		return w.WriteInteger(0)
+	}
+	if b {
+		return w.writeString(fmt.Sprintf("%ct%s", Boolean, CRLF))
+	}
+	return w.writeString(fmt.Sprintf("%cf%s", Boolean, CRLF))
+}
+
+// WriteBulkString writes a RESP bulk string. An empty s is sent as an empty
+// bulk string, not a null; use WriteNullBulkString or WriteNull for that.
 func (w *Writer) WriteBulkString(s string) error {
 	if s == "" {
-		return w.writeString(fmt.Sprintf("%c-1%s", BulkString, CRLF))
+		return w.WriteEmptyBulkString()
 	}
 	return w.writeString(fmt.Sprintf("%c%d%s%s%s", BulkString, len(s), CRLF, s, CRLF))
 }
 
+// WriteEmptyBulkString writes a zero-length bulk string, `$0\r\n\r\n`
+func (w *Writer) WriteEmptyBulkString() error {
+	return w.writeString(fmt.Sprintf("%c0%s%s", BulkString, CRLF, CRLF))
+}
+
+// WriteNullBulkString writes the RESP2 null bulk string, `$-1\r\n`
+func (w *Writer) WriteNullBulkString() error {
+	return w.writeString(fmt.Sprintf("%c-1%s", BulkString, CRLF))
+}
+
 // WriteArray writes a RESP array header
 func (w *Writer) WriteArray(length int) error {
 	if length < 0 {
@@ -168,8 +607,161 @@ func (w *Writer) WriteArray(length int) error {
 	return w.writeString(fmt.Sprintf("%c%d%s", Array, length, CRLF))
 }
 
-// writeString writes a string and flushes the writer
+// WriteDouble writes a RESP3 double. RESP2 has no double type, so the
+// decimal form is sent as a bulk string. Infinities and NaN use redis's
+// textual forms inf, -inf and nan in both protocols.
+func (w *Writer) WriteDouble(f float64) error {
+	s := FormatDouble(f)
+	if w.proto < RESP3 {
+		return w.WriteBulkString(s)
+	}
+	return w.writeString(fmt.Sprintf("%c%s%s", Double, s, CRLF))
+}
+
+// WriteBigNumber writes a RESP3 big number for integers outside the int64
+// range. RESP2 has no big number type, so the decimal form is sent as a bulk
+// string.
+func (w *Writer) WriteBigNumber(n *big.Int) error {
+	s := n.String()
+	if w.proto < RESP3 {
+		return w.WriteBulkString(s)
+	}
+	return w.writeString(fmt.Sprintf("%c%s%s", BigNumber, s, CRLF))
+}
+
+// FormatDouble formats a float the way redis does on the wire, as in RESP3
+// doubles and the bulk strings sent for them to RESP2 clients
+func FormatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// WriteNull writes a null: `_` for RESP3 and a null bulk string for RESP2
+func (w *Writer) WriteNull() error {
+	if w.proto >= RESP3 {
+		return w.writeString(fmt.Sprintf("%c%s", Null, CRLF))
+	}
+	return w.WriteNullBulkString()
+}
+
+// WriteMap writes a map header for length key/value pairs. RESP2 has no
+// map type, so the pairs are sent as a flat array of 2*length elements.
+func (w *Writer) WriteMap(length int) error {
+	if w.proto >= RESP3 {
+		return w.writeString(fmt.Sprintf("%c%d%s", Map, length, CRLF))
+	}
+	return w.WriteArray(length * 2)
+}
+
+// WriteRaw writes p as is. It must hold exactly one complete RESP value,
+// encoded for the protocol version in use; nothing is checked. Inside a
+// stream each call writes one element, as with WriteValue.
+func (w *Writer) WriteRaw(p []byte) error {
+	if w.stream != nil {
+		w.stream.count++
+	}
+	return w.writeString(string(p))
+}
+
+// WriteSet writes a RESP3 set header for length elements. RESP2 has no
+// set type, so RESP2 clients receive an array.
+func (w *Writer) WriteSet(length int) error {
+	if w.proto >= RESP3 {
+		return w.writeString(fmt.Sprintf("%c%d%s", Set, length, CRLF))
+	}
+	return w.WriteArray(length)
+}
+
+// WriteAttributes writes a RESP3 attribute frame, which annotates the
+// reply written right after it. RESP2 has no attribute type, so nothing is
+// written for RESP2 clients.
+func (w *Writer) WriteAttributes(attrs map[string]interface{}) error {
+	if w.proto < RESP3 || len(attrs) == 0 {
+		return nil
+	}
+
+	if err := w.writeString(fmt.Sprintf("%c%d%s", Attribute, len(attrs), CRLF)); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(attrs) {
+		if err := w.WriteBulkString(key); err != nil {
+			return err
+		}
+		if err := w.writeValue(attrs[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WritePush writes a RESP3 push frame of the given kind, such as
+// "invalidate", followed by its elements. RESP2 has no push type, so
+// ErrPushUnsupported is returned.
+func (w *Writer) WritePush(kind string, elements ...interface{}) error {
+	if w.proto < RESP3 {
+		return ErrPushUnsupported
+	}
+	if err := w.writeString(fmt.Sprintf("%c%d%s", Push, len(elements)+1, CRLF)); err != nil {
+		return err
+	}
+	if err := w.WriteBulkString(kind); err != nil {
+		return err
+	}
+	for _, e := range elements {
+		if err := w.writeValue(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteVerbatim writes a RESP3 verbatim string with a three character
+// format such as "txt" or "mkd". RESP2 has no verbatim type, so the content
+// is sent as a plain bulk string.
+func (w *Writer) WriteVerbatim(format, s string) error {
+	if len(format) != 3 {
+		return fmt.Errorf("verbatim format must be 3 characters: %q", format)
+	}
+	if w.proto < RESP3 {
+		return w.WriteBulkString(s)
+	}
+	return w.writeString(fmt.Sprintf("%c%d%s%s:%s%s", VerbatimString, len(s)+4, CRLF, format, s, CRLF))
+}
+
+// writeString writes a string and flushes the writer. While a stream is
+// buffered for a RESP2 client the string is held back until EndStream.
 func (w *Writer) writeString(s string) error {
+	if err := w.count(len(s)); err != nil {
+		return err
+	}
+	if w.stream != nil && w.stream.buffered {
+		w.stream.buf.WriteString(s)
+		return nil
+	}
+	return w.send(s)
+}
+
+// count adds n bytes to the size of the current reply, failing if that
+// would exceed the reply limit
+func (w *Writer) count(n int) error {
+	if w.tooLarge || (w.limit > 0 && w.size+int64(n) > w.limit) {
+		w.tooLarge = true
+		return ErrReplyTooLarge
+	}
+	w.size += int64(n)
+	return nil
+}
+
+// send writes s to the underlying writer and flushes it
+func (w *Writer) send(s string) error {
+	w.sent = true
 	_, err := w.WriteString(s)
 	if err != nil {
 		return err
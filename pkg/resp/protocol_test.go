@@ -0,0 +1,238 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadVerbatimString(t *testing.T) {
+	r := NewReader(strings.NewReader("=13\r\nmkd:# Heading\r\n"))
+	v, err := r.ReadObject()
+	if err != nil {
+		t.Fatalf("ReadObject: %v", err)
+	}
+	if v != "# Heading" {
+		t.Errorf("ReadObject = %#v, want %q", v, "# Heading")
+	}
+	if format := r.VerbatimFormat(); format != "mkd" {
+		t.Errorf("VerbatimFormat = %q, want mkd", format)
+	}
+}
+
+func TestReadCompressed(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetProtocol(RESP3)
+	if err := w.WriteCompressed([]byte(strings.Repeat("a", 4096))); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	frame := buf.String()
+
+	// Readers leave payloads compressed unless asked, as a server must
+	r := NewReader(strings.NewReader(frame))
+	if v, err := r.ReadObject(); err != nil || r.VerbatimFormat() != CompressedFormat || len(fmt.Sprint(v)) >= 4096 {
+		t.Errorf("ReadObject = %d bytes of %q, %v, want the compressed payload", len(fmt.Sprint(v)), r.VerbatimFormat(), err)
+	}
+
+	r = NewReader(strings.NewReader(frame + frame))
+	r.SetDecompress(true)
+	if v, err := r.ReadObject(); err != nil || v != strings.Repeat("a", 4096) {
+		t.Errorf("ReadObject with SetDecompress = %d bytes, %v, want 4096", len(fmt.Sprint(v)), err)
+	}
+	reply, err := r.ReadReply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := reply.Str(); s != strings.Repeat("a", 4096) {
+		t.Errorf("ReadReply with SetDecompress = %d bytes, want 4096", len(s))
+	}
+
+	// A payload inflating past the bulk length limit is rejected
+	r = NewReader(strings.NewReader(frame + frame))
+	r.SetDecompress(true)
+	r.SetMaxBulkLength(1024)
+	if _, err := r.ReadObject(); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("ReadObject past the limit error = %v, want ErrInvalidFormat", err)
+	}
+	if _, err := r.ReadReply(); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("ReadReply past the limit error = %v, want ErrInvalidFormat", err)
+	}
+}
+
+// malformed holds frames that must be rejected as protocol errors
+var malformed = []struct {
+	name, input string
+}{
+	{"empty bulk length", "$\r\n"},
+	{"blank bulk length", "$ \r\n"},
+	{"non-numeric bulk length", "$abc\r\nabc\r\n"},
+	{"negative bulk length", "$-2\r\n"},
+	{"overflowing bulk length", "$99999999999999999999\r\n"},
+	{"bulk missing CRLF", "$3\r\nabcXY"},
+	{"bulk truncated", "$10\r\nabc"},
+	{"empty array length", "*\r\n"},
+	{"non-numeric array length", "*x\r\n"},
+	{"negative array length", "*-5\r\n"},
+	{"overflowing array length", "*99999999999999999999\r\n"},
+	{"array truncated", "*2\r\n$1\r\na\r\n"},
+	{"oversized array length", "*9223372036854775807\r\n"},
+	{"array length past the limit", "*1048577\r\n"},
+	{"oversized map length", "%9223372036854775807\r\n"},
+	{"oversized set length", "~9223372036854775807\r\n"},
+	{"oversized push length", ">9223372036854775807\r\n"},
+	{"oversized attribute length", "|9223372036854775807\r\n"},
+	{"empty integer", ":\r\n"},
+	{"padded integer", ": 1\r\n"},
+	{"overflowing integer", ":9223372036854775808\r\n"},
+	{"line missing CR", "+OK\n"},
+	{"line missing CRLF", "+OK"},
+	{"length missing CR", "$3\nabc\r\n"},
+}
+
+func TestReadObjectMalformed(t *testing.T) {
+	for _, tt := range malformed {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(tt.input)).ReadObject()
+			if !errors.Is(err, ErrInvalidFormat) {
+				t.Errorf("ReadObject(%q) error = %v, want ErrInvalidFormat", tt.input, err)
+			}
+		})
+	}
+}
+
+func TestReadReplyMalformed(t *testing.T) {
+	for _, tt := range malformed {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(tt.input)).ReadReply()
+			if !errors.Is(err, ErrInvalidFormat) {
+				t.Errorf("ReadReply(%q) error = %v, want ErrInvalidFormat", tt.input, err)
+			}
+		})
+	}
+}
+
+func TestParseCommandMalformed(t *testing.T) {
+	for _, tt := range []struct {
+		name, input string
+	}{
+		{"empty array length", "*\r\n"},
+		{"negative array length", "*-5\r\n"},
+		{"overflowing array length", "*99999999999999999999\r\n"},
+		{"oversized array length", "*9223372036854775807\r\n"},
+		{"array length past the limit", "*2000000000\r\n"},
+		{"oversized nested array", "*1\r\n*9223372036854775807\r\n"},
+		{"empty bulk length", "*1\r\n$\r\n"},
+		{"negative bulk length", "*1\r\n$-2\r\n"},
+		{"overflowing bulk length", "*1\r\n$99999999999999999999\r\n"},
+		{"bulk missing CRLF", "*1\r\n$4\r\nPINGXY"},
+		{"length missing CR", "*1\n$4\r\nPING\r\n"},
+		{"truncated", "*2\r\n$4\r\nECHO\r\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := NewReader(strings.NewReader(tt.input)).ParseCommand()
+			if !errors.Is(err, ErrInvalidFormat) {
+				t.Errorf("ParseCommand(%q) error = %v, want ErrInvalidFormat", tt.input, err)
+			}
+		})
+	}
+}
+
+func TestSetMaxAggregateLength(t *testing.T) {
+	r := NewReader(strings.NewReader("*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\n*3\r\n"))
+	r.SetMaxAggregateLength(2)
+	if name, args, err := r.ParseCommand(); err != nil || name != "ECHO" || len(args) != 1 {
+		t.Fatalf("ParseCommand = %q, %q, %v, want ECHO hi", name, args, err)
+	}
+	if _, _, err := r.ParseCommand(); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("ParseCommand past the limit error = %v, want ErrInvalidFormat", err)
+	}
+}
+
+// TestWriteBool checks that Go bools are written as RESP3 booleans on
+// every path that takes a Go value, and as integers for RESP2
+func TestWriteBool(t *testing.T) {
+	type flags struct {
+		Active bool `json:"active"`
+	}
+	tests := []struct {
+		proto           int
+		want, marshaled string
+	}{
+		{RESP2, ":1\r\n*2\r\n:0\r\n:1\r\n*2\r\n$6\r\nactive\r\n:1\r\n", ":0\r\n"},
+		{RESP3, "#t\r\n*2\r\n#f\r\n#t\r\n%1\r\n$6\r\nactive\r\n#t\r\n", "#f\r\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.SetProtocol(tt.proto)
+		for _, err := range []error{
+			w.WriteValue(true),
+			w.WriteValue([]interface{}{false, true}),
+			w.WriteStruct(flags{Active: true}),
+		} {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		w.Flush()
+		if buf.String() != tt.want {
+			t.Errorf("RESP%d wrote %q, want %q", tt.proto, buf.String(), tt.want)
+		}
+
+		if p, err := Marshal(false, tt.proto); err != nil || string(p) != tt.marshaled {
+			t.Errorf("Marshal(false, RESP%d) = %q, %v, want %q", tt.proto, p, err, tt.marshaled)
+		}
+	}
+}
+
+// TestWriteReply checks that a reply read with ReadReply is written back
+// by WriteValue unchanged, except that a RESP3 client gets nulls as _
+func TestWriteReply(t *testing.T) {
+	for _, frame := range []string{
+		"+OK\r\n",
+		"-ERR bad\r\n",
+		":42\r\n",
+		"$5\r\nhello\r\n",
+		"$0\r\n\r\n",
+		"*-1\r\n",
+		"_\r\n",
+		"#t\r\n",
+		",1.5\r\n",
+		"(12345678901234567890\r\n",
+		"=7\r\ntxt:abc\r\n",
+		"*3\r\n:1\r\n$1\r\na\r\n*1\r\n_\r\n",
+		"%1\r\n+key\r\n~2\r\n:1\r\n:2\r\n",
+	} {
+		reply, err := NewReader(strings.NewReader(frame)).ReadReply()
+		if err != nil {
+			t.Errorf("ReadReply(%q): %v", frame, err)
+			continue
+		}
+
+		var b strings.Builder
+		w := NewWriter(&b)
+		w.SetProtocol(RESP3)
+		if err := w.WriteValue(reply); err != nil {
+			t.Errorf("WriteValue(%q): %v", frame, err)
+			continue
+		}
+		w.Flush()
+		if b.String() != frame {
+			t.Errorf("WriteValue(ReadReply(%q)) wrote %q", frame, b.String())
+		}
+	}
+
+	reply, _ := NewReader(strings.NewReader("$-1\r\n")).ReadReply()
+	var b strings.Builder
+	w := NewWriter(&b)
+	w.SetProtocol(RESP3)
+	w.WriteValue(reply)
+	w.Flush()
+	if b.String() != "_\r\n" {
+		t.Errorf("WriteValue of a null bulk string wrote %q, want _", b.String())
+	}
+}
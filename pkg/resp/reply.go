@@ -0,0 +1,299 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// ErrReplyType is returned by a Reply accessor called on a reply of an
+// incompatible type
+var ErrReplyType = errors.New("unexpected reply type")
+
+// Reply is a decoded RESP value with typed accessors, for clients that
+// would otherwise type-assert the interface values of ReadObject. Scalars
+// are held inline and the elements of an aggregate are stored in one
+// contiguous slice.
+type Reply struct {
+	// Type is the RESP type byte of the value, such as BulkString or Map.
+	// Null bulk strings and arrays keep their type and report IsNil.
+	Type byte
+
+	str    string // text of strings, errors, big numbers and doubles
+	num    int64  // integers and booleans
+	float  float64
+	format string // verbatim string format
+	null   bool
+	elems  []Reply
+}
+
+// ReadReply reads the next value as a Reply. Attribute frames are skipped
+// and available from Attributes, as with ReadObject. An error reply is
+// returned as a Reply of type Error, not as an error. Like ReadObject it
+// fails with io.EOF if the stream ends before the value starts and with
+// ErrTruncated if it ends partway through.
+func (r *Reader) ReadReply() (*Reply, error) {
+	if _, err := r.Peek(1); err != nil {
+		return nil, err
+	}
+	reply := new(Reply)
+	if err := r.readReply(reply); err != nil {
+		return nil, truncated(err)
+	}
+	return reply, nil
+}
+
+// readReply decodes the next value into reply
+func (r *Reader) readReply(reply *Reply) error {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	for typ == Attribute {
+		if r.attrs, err = r.readMap(); err != nil {
+			return err
+		}
+		if typ, err = r.ReadByte(); err != nil {
+			return err
+		}
+	}
+	reply.Type = typ
+
+	switch typ {
+	case SimpleString, Error:
+		reply.str, err = r.readLine()
+	case Integer:
+		reply.num, err = r.readInteger()
+	case Boolean:
+		var line string
+		if line, err = r.readLine(); err == nil {
+			switch line {
+			case "t":
+				reply.num = 1
+			case "f":
+			default:
+				err = ErrInvalidFormat
+			}
+		}
+	case Double:
+		reply.float, err = r.readDouble()
+	case BigNumber:
+		var n *big.Int
+		if n, err = r.readBigNumber(); err == nil {
+			reply.str = n.String()
+		}
+	case BulkString:
+		var b []byte
+		if b, err = r.readBulkBytes(); err == nil {
+			reply.str, reply.null = string(b), b == nil
+		}
+	case VerbatimString:
+		var b []byte
+		if b, err = r.readBulkBytes(); err == nil {
+			if len(b) < 4 || b[3] != ':' {
+				return ErrInvalidFormat
+			}
+			reply.format, reply.str = string(b[:3]), string(b[4:])
+			if reply.format == CompressedFormat && r.inflate {
+				reply.str, err = decompress(reply.str, r.maxBulk)
+			}
+		}
+	case Null:
+		reply.null = true
+		_, err = r.readLine()
+	case Array, Set, Push, Map:
+		err = r.readReplyElements(reply)
+	case StreamEnd:
+		err = ErrUnexpectedEnd
+	default:
+		err = fmt.Errorf("unknown RESP type byte: %c", typ)
+	}
+	return err
+}
+
+// readReplyElements reads the elements of an aggregate. Maps are stored
+// flattened as alternating keys and values.
+func (r *Reader) readReplyElements(reply *Reply) error {
+	line, err := r.readLine()
+	if err != nil {
+		return err
+	}
+
+	if line == StreamedLength {
+		for {
+			b, err := r.Peek(1)
+			if err != nil {
+				return err
+			}
+			if b[0] == StreamEnd {
+				r.ReadByte()
+				if line, err := r.readLine(); err != nil || line != "" {
+					return ErrInvalidFormat
+				}
+				if reply.elems == nil {
+					reply.elems = []Reply{}
+				}
+				return nil
+			}
+			reply.elems = append(reply.elems, Reply{})
+			if err := r.readReply(&reply.elems[len(reply.elems)-1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	n, err := r.parseAggregateLength(line)
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		reply.null = true
+		return nil
+	}
+	if reply.Type == Map {
+		n *= 2
+	}
+
+	reply.elems = make([]Reply, 0, min(n, preallocLength))
+	for i := int64(0); i < n; i++ {
+		reply.elems = append(reply.elems, Reply{})
+		if err := r.readReply(&reply.elems[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsNil reports whether the reply is a null, a null bulk string or a null
+// array
+func (r *Reply) IsNil() bool {
+	return r.null
+}
+
+// Str returns the text of a string, error or big number reply, or the
+// decimal form of a number
+func (r *Reply) Str() (string, error) {
+	switch r.Type {
+	case SimpleString, Error, BulkString, VerbatimString, BigNumber:
+		if r.null {
+			return "", ErrReplyType
+		}
+		return r.str, nil
+	case Integer:
+		return strconv.FormatInt(r.num, 10), nil
+	case Double:
+		return FormatDouble(r.float), nil
+	}
+	return "", ErrReplyType
+}
+
+// Int returns an integer or boolean reply, or parses a string reply as an
+// integer, as RESP2 servers send many numbers as strings
+func (r *Reply) Int() (int64, error) {
+	switch r.Type {
+	case Integer, Boolean:
+		return r.num, nil
+	case SimpleString, BulkString:
+		if !r.null {
+			return strconv.ParseInt(r.str, 10, 64)
+		}
+	}
+	return 0, ErrReplyType
+}
+
+// Float returns a double or integer reply, or parses a string reply as a
+// float, as RESP2 servers send doubles as strings
+func (r *Reply) Float() (float64, error) {
+	switch r.Type {
+	case Double:
+		return r.float, nil
+	case Integer:
+		return float64(r.num), nil
+	case SimpleString, BulkString:
+		if !r.null {
+			return strconv.ParseFloat(r.str, 64)
+		}
+	}
+	return 0, ErrReplyType
+}
+
+// Slice returns the elements of an array, set or push reply, or the keys
+// and values of a map reply alternating. A null array has no elements.
+func (r *Reply) Slice() ([]Reply, error) {
+	switch r.Type {
+	case Array, Set, Push, Map:
+		return r.elems, nil
+	}
+	return nil, ErrReplyType
+}
+
+// Err returns the error carried by an error reply, or nil for any other
+// reply
+func (r *Reply) Err() error {
+	if r.Type != Error {
+		return nil
+	}
+	return errors.New(r.str)
+}
+
+// Format returns the format of a verbatim string reply, such as "txt"
+func (r *Reply) Format() string {
+	return r.format
+}
+
+// writeReply writes a decoded reply back as the same RESP type, so that a
+// proxy can relay it. Types the client's protocol lacks are sent as the
+// Writer methods send them.
+func (w *Writer) writeReply(r *Reply) error {
+	switch r.Type {
+	case SimpleString:
+		return w.WriteSimpleString(r.str)
+	case Error:
+		return w.WriteError(errors.New(r.str))
+	case Integer:
+		return w.WriteInteger(r.num)
+	case Boolean:
+		return w.WriteBoolean(r.num != 0)
+	case Double:
+		return w.WriteDouble(r.float)
+	case BigNumber:
+		n, ok := new(big.Int).SetString(r.str, 10)
+		if !ok {
+			return ErrInvalidFormat
+		}
+		return w.WriteBigNumber(n)
+	case BulkString:
+		if r.null {
+			return w.WriteNull()
+		}
+		return w.WriteBulkString(r.str)
+	case VerbatimString:
+		return w.WriteVerbatim(r.format, r.str)
+	case Null:
+		return w.WriteNull()
+	case Array, Set, Push, Map:
+		if r.null {
+			return w.WriteArray(-1)
+		}
+		var err error
+		switch r.Type {
+		case Set:
+			err = w.WriteSet(len(r.elems))
+		case Map:
+			err = w.WriteMap(len(r.elems) / 2)
+		default:
+			err = w.WriteArray(len(r.elems))
+		}
+		if err != nil {
+			return err
+		}
+		for i := range r.elems {
+			if err := w.writeReply(&r.elems[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported reply type %c", r.Type)
+}
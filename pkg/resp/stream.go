@@ -0,0 +1,279 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// StreamedLength is the length marker of a RESP3 streamed aggregate
+const StreamedLength = "?"
+
+var (
+	ErrStreamActive  = errors.New("stream already in progress")
+	ErrNoStream      = errors.New("no stream in progress")
+	ErrUnexpectedEnd = errors.New("unexpected end of stream marker")
+)
+
+// stream tracks a streamed array being written
+type stream struct {
+	// buffered is set for RESP2 writers, which have no streamed type; the
+	// elements are buffered and sent as a regular array by EndStream
+	buffered bool
+	buf      strings.Builder
+	count    int
+}
+
+// BeginStreamArray starts an array whose length is not known up front.
+// Elements are written with WriteValue and the array is terminated with
+// EndStream. RESP3 writers send `*?` and each element immediately; RESP2
+// writers buffer the elements and send a regular array on EndStream.
+func (w *Writer) BeginStreamArray() error {
+	if w.stream != nil {
+		return ErrStreamActive
+	}
+
+	w.stream = &stream{buffered: w.proto < RESP3}
+	if w.stream.buffered {
+		return nil
+	}
+	return w.writeString(fmt.Sprintf("%c%s%s", Array, StreamedLength, CRLF))
+}
+
+// Streaming reports whether a stream started by BeginStreamArray has not
+// yet been ended
+func (w *Writer) Streaming() bool {
+	return w.stream != nil
+}
+
+// EndStream terminates the array started by BeginStreamArray
+func (w *Writer) EndStream() error {
+	if w.stream == nil {
+		return ErrNoStream
+	}
+
+	st := w.stream
+	w.stream = nil
+	if st.buffered {
+		// The elements were counted against the reply limit as they were
+		// buffered, so only the header is counted here
+		header := fmt.Sprintf("%c%d%s", Array, st.count, CRLF)
+		if err := w.count(len(header)); err != nil {
+			return err
+		}
+		return w.send(header + st.buf.String())
+	}
+	return w.writeString(fmt.Sprintf("%c%s", StreamEnd, CRLF))
+}
+
+// WriteValue writes a Go value as the matching RESP type. Supported types
+// are nil, string, []byte, Verbatim, *Reply, integers, *big.Int, floats, bool, error,
+// []string, []interface{}, SetValue, map[string]string and map[string]interface{};
+// slices and maps are written recursively. A bool is a RESP3 boolean, or 1 or 0
+// for RESP2 clients. Inside a stream each call writes one element.
+func (w *Writer) WriteValue(v interface{}) error {
+	if w.stream != nil {
+		w.stream.count++
+	}
+	return w.writeValue(v)
+}
+
+// writeValue writes a single value without stream accounting
+func (w *Writer) writeValue(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		return w.WriteNull()
+	case string:
+		return w.WriteBulkString(v)
+	case []byte:
+		return w.WriteBulkString(string(v))
+	case Verbatim:
+		return w.WriteVerbatim(v.Format, v.Text)
+	case *Reply:
+		return w.writeReply(v)
+	case int:
+		return w.WriteInteger(int64(v))
+	case int32:
+		return w.WriteInteger(int64(v))
+	case int64:
+		return w.WriteInteger(v)
+	case uint32:
+		return w.WriteInteger(int64(v))
+	case float32:
+		return w.WriteDouble(float64(v))
+	case float64:
+		return w.WriteDouble(v)
+	case *big.Int:
+		return w.WriteBigNumber(v)
+	case bool:
+		return w.WriteBoolean(v)
+	case error:
+		return w.WriteError(v)
+	case []string:
+		if err := w.WriteArray(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := w.WriteBulkString(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case SetValue:
+		if err := w.WriteSet(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := w.writeValue(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if err := w.WriteArray(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := w.writeValue(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]string:
+		if err := w.WriteMap(len(v)); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(v) {
+			if err := w.WriteBulkString(key); err != nil {
+				return err
+			}
+			if err := w.WriteBulkString(v[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		if err := w.WriteMap(len(v)); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(v) {
+			if err := w.WriteBulkString(key); err != nil {
+				return err
+			}
+			if err := w.writeValue(v[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// sortedKeys returns the keys of m in sorted order so maps encode deterministically
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readStreamedArray reads the elements of a streamed array up to its end marker
+func (r *Reader) readStreamedArray() ([]interface{}, error) {
+	var array []interface{}
+	err := r.readStreamedElements(func(value interface{}) error {
+		array = append(array, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if array == nil {
+		array = []interface{}{}
+	}
+	return array, nil
+}
+
+// readStreamedElements calls fn for each element of a streamed array whose
+// `*?` header has already been consumed, stopping at the end marker
+func (r *Reader) readStreamedElements(fn func(value interface{}) error) error {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return err
+		}
+		if b[0] == StreamEnd {
+			r.ReadByte()
+			line, err := r.readLine()
+			if err != nil {
+				return err
+			}
+			if line != "" {
+				return ErrInvalidFormat
+			}
+			return nil
+		}
+
+		value, err := r.ReadObject()
+		if err != nil {
+			return err
+		}
+		if err := fn(value); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadStream reads an array and calls fn for each element as it arrives,
+// without collecting the elements. Both streamed (`*?`) and regular arrays
+// are accepted, so callers can consume large replies with bounded memory.
+func (r *Reader) ReadStream(fn func(value interface{}) error) error {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if typ != Array {
+		return fmt.Errorf("expected array, got RESP type byte: %c", typ)
+	}
+
+	line, err := r.readLine()
+	if err != nil {
+		return err
+	}
+	if line == StreamedLength {
+		return r.readStreamedElements(fn)
+	}
+
+	length, err := parseLength(line)
+	if err != nil {
+		return err
+	}
+	for i := int64(0); i < length; i++ {
+		value, err := r.ReadObject()
+		if err != nil {
+			return err
+		}
+		if err := fn(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadArrayFunc is ReadStream with the position of each element passed to
+// fn, for callers such as proxies that forward or transform elements one
+// at a time. Each element is decoded whole, so only nesting below the top
+// level is held in memory. If fn returns an error, reading stops and the
+// rest of the array is left unread, so the reader is no longer in sync.
+func (r *Reader) ReadArrayFunc(fn func(index int, value interface{}) error) error {
+	index := 0
+	return r.ReadStream(func(value interface{}) error {
+		err := fn(index, value)
+		index++
+		return err
+	})
+}
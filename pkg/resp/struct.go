@@ -0,0 +1,180 @@
+package resp
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// WriteStruct writes a struct, or a pointer to one, as a map of field names
+// to values: a RESP3 map, or a flat array of alternating names and values
+// for RESP2. Fields are written in declaration order and named like
+// encoding/json does, honoring `json` tags including "-" and omitempty;
+// unexported fields are skipped and embedded structs, but not embedded
+// struct pointers, are flattened. Nested structs, slices, arrays and maps
+// with string keys are written recursively, and values implementing
+// encoding.TextMarshaler, such as time.Time, as bulk strings. Inside a
+// stream each call writes one element.
+func (w *Writer) WriteStruct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("WriteStruct of non-struct type %T", v)
+	}
+
+	if w.stream != nil {
+		w.stream.count++
+	}
+	return w.writeReflect(rv)
+}
+
+// structField is a field written by WriteStruct
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields lists the fields of a struct type in declaration order,
+// with the fields of embedded structs in place of the embedded field
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for _, inner := range structFields(f.Type) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+	return fields
+}
+
+// textMarshaler is the type of encoding.TextMarshaler
+var textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// writeReflect writes a value found by WriteStruct
+func (w *Writer) writeReflect(v reflect.Value) error {
+	if !v.IsValid() {
+		return w.WriteNull()
+	}
+	if v.Type().Implements(textMarshaler) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return w.WriteNull()
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		return w.WriteBulkString(string(text))
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return w.WriteNull()
+		}
+		return w.writeReflect(v.Elem())
+	case reflect.String:
+		return w.WriteBulkString(v.String())
+	case reflect.Bool:
+		return w.WriteBoolean(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return w.WriteInteger(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return w.WriteInteger(int64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return w.WriteDouble(v.Float())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return w.WriteBulkString(string(v.Bytes()))
+		}
+		if err := w.WriteArray(v.Len()); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := w.writeReflect(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		if err := w.WriteMap(len(keys)); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := w.WriteBulkString(key.String()); err != nil {
+				return err
+			}
+			if err := w.writeReflect(v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		var fields []structField
+		for _, f := range structFields(v.Type()) {
+			if !f.omitEmpty || !isEmpty(v.FieldByIndex(f.index)) {
+				fields = append(fields, f)
+			}
+		}
+		if err := w.WriteMap(len(fields)); err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if err := w.WriteBulkString(f.name); err != nil {
+				return err
+			}
+			if err := w.writeReflect(v.FieldByIndex(f.index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported value type %s", v.Type())
+	}
+}
+
+// isEmpty reports whether a field tagged omitempty is left out, using the
+// definition of empty of encoding/json
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		return false
+	default:
+		return v.IsZero()
+	}
+}
@@ -0,0 +1,502 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+)
+
+// Version is the server version reported to clients
+const Version = "0.1.0"
+
+// newBuiltins creates the extension holding the server's built-in commands
+func (s *Server) newBuiltins() *command.Extension {
+	ext := command.NewExtension("builtin")
+
+	hello := command.New("HELLO")
+	hello.Description = "Negotiate the protocol version and connection options"
+	hello.Handler = s.hello
+	ext.AddCommand(hello)
+	s.OnReset(func(ctx *command.Context) {
+		ctx.Session.SetProtocol(resp.RESP2)
+		ctx.Session.SetCompression(false)
+	})
+
+	client := command.New("CLIENT")
+	client.Description = "Inspect and name client connections"
+	client.MinArgs = 1
+	client.Handler = s.client
+	ext.AddCommand(client)
+	s.OnReset(func(ctx *command.Context) {
+		ctx.Session.SetName("")
+	})
+
+	sel := command.New("SELECT")
+	sel.Description = "Select the logical database for the connection"
+	sel.MinArgs = 1
+	sel.MaxArgs = 1
+	sel.Handler = s.selectDB
+	ext.AddCommand(sel)
+	s.OnReset(func(ctx *command.Context) {
+		ctx.Session.SetDB(0)
+	})
+
+	monitor := command.New("MONITOR")
+	monitor.Description = "Stream every command executed by the server"
+	monitor.MaxArgs = 0
+	monitor.Handler = s.monitor
+	ext.AddCommand(monitor)
+	s.OnReset(func(ctx *command.Context) {
+		if c, ok := ctx.Conn.(*conn); ok && ctx.Session.Monitoring() {
+			s.removeMonitor(c)
+			ctx.Session.SetMonitoring(false)
+		}
+	})
+
+	reset := command.New("RESET")
+	reset.Description = "Return the connection to its initial state"
+	reset.MaxArgs = 0
+	reset.Handler = s.reset
+	ext.AddCommand(reset)
+
+	ping := command.New("PING")
+	ping.Description = "Check the connection"
+	ping.MaxArgs = 1
+	ping.Handler = s.ping
+	ext.AddCommand(ping)
+
+	echo := command.New("ECHO")
+	echo.Description = "Reply with the given message"
+	echo.MinArgs = 1
+	echo.MaxArgs = 1
+	echo.Handler = func(ctx *command.Context) error {
+		return ctx.Reply(ctx.Args[1])
+	}
+	ext.AddCommand(echo)
+
+	info := command.New("INFO")
+	info.Description = "Report server information and statistics"
+	info.Handler = s.info
+	ext.AddCommand(info)
+
+	cmd := command.New("COMMAND")
+	cmd.Description = "Describe the commands served"
+	cmd.Handler = s.command
+	ext.AddCommand(cmd)
+
+	slowlog := command.New("SLOWLOG")
+	slowlog.Description = "Read or reset the log of slow commands"
+	slowlog.MinArgs = 1
+	slowlog.Handler = s.slowlog
+	ext.AddCommand(slowlog)
+
+	config := command.New("CONFIG")
+	config.Description = "Read and change server options"
+	config.MinArgs = 1
+	config.Handler = s.config
+	ext.AddCommand(config)
+
+	// WAIT and WAITAOF are compatibility shims: there are no replicas and
+	// AOF writes are not acknowledged, so they report zero right away
+	wait := command.New("WAIT")
+	wait.Description = "Wait for replicas to acknowledge writes (always 0 replicas)"
+	wait.MinArgs = 2
+	wait.MaxArgs = 2
+	wait.Handler = s.wait
+	ext.AddCommand(wait)
+
+	waitAOF := command.New("WAITAOF")
+	waitAOF.Description = "Wait for writes to be fsynced to the AOF (always acknowledged by none)"
+	waitAOF.MinArgs = 3
+	waitAOF.MaxArgs = 3
+	waitAOF.Handler = s.waitAOF
+	ext.AddCommand(waitAOF)
+
+	return ext
+}
+
+// hello implements HELLO [protover [AUTH username password] [SETNAME name] [COMPRESS]].
+// COMPRESS is a Goluxis extension announcing that the client decompresses
+// gzip verbatim replies.
+func (s *Server) hello(ctx *command.Context) error {
+	protocol := ctx.Session.Protocol()
+	compress := ctx.Session.Compression()
+	name := ctx.Session.Name()
+
+	if len(ctx.Args) > 1 {
+		v, err := strconv.Atoi(ctx.Args[1])
+		if err != nil || (v != resp.RESP2 && v != resp.RESP3) {
+			return errors.New("NOPROTO unsupported protocol version")
+		}
+		protocol = v
+
+		for i := 2; i < len(ctx.Args); i++ {
+			switch strings.ToUpper(ctx.Args[i]) {
+			case "AUTH":
+				// Authentication is not supported, so any credentials are accepted
+				if i+2 >= len(ctx.Args) {
+					return errors.New("ERR syntax error in HELLO")
+				}
+				i += 2
+			case "SETNAME":
+				if i+1 >= len(ctx.Args) {
+					return errors.New("ERR syntax error in HELLO")
+				}
+				i++
+				name = ctx.Args[i]
+			case "COMPRESS":
+				compress = true
+			default:
+				return errors.New("ERR syntax error in HELLO")
+			}
+		}
+	}
+
+	ctx.Session.SetProtocol(protocol)
+	ctx.Session.SetCompression(compress)
+	ctx.Session.SetName(name)
+
+	if err := ctx.ReplyMap(7); err != nil {
+		return err
+	}
+	ctx.Reply("server")
+	ctx.Reply("goluxis")
+	ctx.Reply("version")
+	ctx.Reply(Version)
+	ctx.Reply("proto")
+	ctx.ReplyInt(int64(protocol))
+	ctx.Reply("id")
+	ctx.ReplyInt(ctx.Session.ID())
+	ctx.Reply("mode")
+	ctx.Reply("standalone")
+	ctx.Reply("role")
+	ctx.Reply("master")
+	ctx.Reply("modules")
+	return ctx.ReplyArray(0)
+}
+
+// client implements CLIENT ID | GETNAME | SETNAME name | SETINFO attr value | LIST
+func (s *Server) client(ctx *command.Context) error {
+	sub := strings.ToUpper(ctx.Args[1])
+	switch {
+	case sub == "ID" && len(ctx.Args) == 2:
+		return ctx.ReplyInt(ctx.Session.ID())
+
+	case sub == "GETNAME" && len(ctx.Args) == 2:
+		name := ctx.Session.Name()
+		if name == "" {
+			return ctx.ReplyNull()
+		}
+		return ctx.Reply(name)
+
+	case sub == "SETNAME" && len(ctx.Args) == 3:
+		name := ctx.Args[2]
+		if !validClientString(name) {
+			return errors.New("ERR Client names cannot contain spaces, newlines or special characters.")
+		}
+		ctx.Session.SetName(name)
+		return ctx.ReplyStatus("OK")
+
+	case sub == "SETINFO" && len(ctx.Args) == 4:
+		attr, value := strings.ToUpper(ctx.Args[2]), ctx.Args[3]
+		if attr != "LIB-NAME" && attr != "LIB-VER" {
+			return fmt.Errorf("ERR Unrecognized option '%s'", ctx.Args[2])
+		}
+		if !validClientString(value) {
+			return fmt.Errorf("ERR %s cannot contain spaces, newlines or special characters.", attr)
+		}
+		if attr == "LIB-NAME" {
+			ctx.Session.SetLibraryName(value)
+		} else {
+			ctx.Session.SetLibraryVersion(value)
+		}
+		return ctx.ReplyStatus("OK")
+
+	case sub == "LIST" && len(ctx.Args) == 2:
+		return ctx.Reply(s.clientList())
+
+	default:
+		return fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try CLIENT HELP.", ctx.Args[1])
+	}
+}
+
+// ping implements PING [message]
+func (s *Server) ping(ctx *command.Context) error {
+	if len(ctx.Args) == 2 {
+		return ctx.Reply(ctx.Args[1])
+	}
+	return ctx.ReplyStatus("PONG")
+}
+
+// selectDB implements SELECT index
+func (s *Server) selectDB(ctx *command.Context) error {
+	index, err := strconv.Atoi(ctx.Args[1])
+	if err != nil {
+		return resp.ErrOutOfRange()
+	}
+	if index < 0 || index >= s.Databases {
+		return errors.New("ERR DB index is out of range")
+	}
+	ctx.Session.SetDB(index)
+	return ctx.ReplyStatus("OK")
+}
+
+// wait implements WAIT numreplicas timeout. Goluxis has no replicas, so it
+// replies 0 without waiting, which clients issuing WAIT after writes
+// accept as "no replica acknowledged".
+func (s *Server) wait(ctx *command.Context) error {
+	if err := parseWaitArgs(ctx.Args[1:]); err != nil {
+		return err
+	}
+	return ctx.ReplyInt(0)
+}
+
+// waitAOF implements WAITAOF numlocal numreplicas timeout, replying that
+// neither the local AOF nor any replica acknowledged the writes
+func (s *Server) waitAOF(ctx *command.Context) error {
+	if err := parseWaitArgs(ctx.Args[1:]); err != nil {
+		return err
+	}
+	if err := ctx.ReplyArray(2); err != nil {
+		return err
+	}
+	ctx.ReplyInt(0)
+	return ctx.ReplyInt(0)
+}
+
+// parseWaitArgs validates the counts and timeout of WAIT and WAITAOF the
+// way redis does, so that malformed calls still fail
+func parseWaitArgs(args []string) error {
+	for i, arg := range args {
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			if i == len(args)-1 {
+				return errors.New("ERR timeout is not an integer or out of range")
+			}
+			return resp.ErrOutOfRange()
+		}
+		if n < 0 && i == len(args)-1 {
+			return errors.New("ERR timeout is negative")
+		}
+	}
+	return nil
+}
+
+// OnReset registers a function that RESET calls to clear per-connection
+// state. Each stateful feature registers its own hook, so RESET itself does
+// not need to know about them. Hooks run in registration order.
+func (s *Server) OnReset(fn func(ctx *command.Context)) {
+	s.hookMu.Lock()
+	defer s.hookMu.Unlock()
+
+	s.resetHooks = append(s.resetHooks, fn)
+}
+
+// reset implements RESET
+func (s *Server) reset(ctx *command.Context) error {
+	s.hookMu.RLock()
+	hooks := s.resetHooks
+	s.hookMu.RUnlock()
+
+	for _, fn := range hooks {
+		fn(ctx)
+	}
+	return ctx.ReplyStatus("RESET")
+}
+
+// monitor implements MONITOR. The connection receives every command
+// executed by other clients as a simple string. While any monitor is
+// connected, each command is formatted and queued for every monitor before
+// it runs, so monitoring a busy server costs throughput; a monitor that
+// cannot keep up is disconnected.
+func (s *Server) monitor(ctx *command.Context) error {
+	if err := ctx.ReplyStatus("OK"); err != nil {
+		return err
+	}
+	c, ok := ctx.Conn.(*conn)
+	if !ok {
+		return nil
+	}
+	ctx.Session.SetMonitoring(true)
+	s.addMonitor(c)
+	return nil
+}
+
+// command implements COMMAND [COUNT | INFO [name ...] | DOCS [name ...] |
+// GETKEYS command [arg ...]]
+func (s *Server) command(ctx *command.Context) error {
+	if len(ctx.Args) == 1 {
+		return s.commandInfo(ctx, s.Commands())
+	}
+
+	sub := strings.ToUpper(ctx.Args[1])
+	switch {
+	case sub == "COUNT" && len(ctx.Args) == 2:
+		return ctx.ReplyInt(int64(len(s.Commands())))
+
+	case sub == "INFO":
+		infos := s.Commands()
+		if len(ctx.Args) == 2 {
+			return s.commandInfo(ctx, infos)
+		}
+		byName := make(map[string]CommandInfo, len(infos))
+		for _, info := range infos {
+			byName[strings.ToLower(info.Name)] = info
+		}
+		// Unknown names get a null entry, as in redis
+		if err := ctx.ReplyArray(len(ctx.Args) - 2); err != nil {
+			return err
+		}
+		for _, name := range ctx.Args[2:] {
+			info, exists := byName[strings.ToLower(name)]
+			if !exists {
+				ctx.ReplyNull()
+				continue
+			}
+			writeCommandInfo(ctx, info)
+		}
+		return nil
+
+	case sub == "DOCS":
+		infos := s.Commands()
+		if len(ctx.Args) > 2 {
+			wanted := make(map[string]bool)
+			for _, name := range ctx.Args[2:] {
+				wanted[strings.ToLower(name)] = true
+			}
+			filtered := infos[:0]
+			for _, info := range infos {
+				if wanted[strings.ToLower(info.Name)] {
+					filtered = append(filtered, info)
+				}
+			}
+			infos = filtered
+		}
+
+		if err := ctx.ReplyMap(len(infos)); err != nil {
+			return err
+		}
+		for _, info := range infos {
+			deprecated := info.Command.Deprecated
+			ctx.Reply(strings.ToLower(info.Name))
+			if deprecated != "" {
+				ctx.ReplyMap(5)
+			} else {
+				ctx.ReplyMap(3)
+			}
+			ctx.Reply("summary")
+			ctx.Reply(info.Command.Description)
+			ctx.Reply("group")
+			ctx.Reply(info.Extension)
+			ctx.Reply("arity")
+			ctx.ReplyInt(int64(info.Command.Arity()))
+			if deprecated != "" {
+				ctx.Reply("doc_flags")
+				ctx.ReplyValue([]string{"deprecated"})
+				ctx.Reply("replaced_by")
+				ctx.Reply(deprecated)
+			}
+		}
+		return nil
+
+	case sub == "GETKEYS" && len(ctx.Args) >= 3:
+		args := ctx.Args[2:]
+		cmd, err := s.lookup(args[0], args[1:])
+		if err != nil {
+			return errors.New("ERR Invalid command specified")
+		}
+		if !cmd.CheckArity(len(args) - 1) {
+			return errors.New("ERR Invalid number of arguments specified for command")
+		}
+		keys := cmd.KeyArgs(args)
+		if len(keys) == 0 {
+			return errors.New("ERR The command has no key arguments")
+		}
+		return ctx.ReplyValue(keys)
+
+	default:
+		return fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try COMMAND HELP.", ctx.Args[1])
+	}
+}
+
+// commandInfo replies with the COMMAND INFO entries of infos
+func (s *Server) commandInfo(ctx *command.Context, infos []CommandInfo) error {
+	if err := ctx.ReplyArray(len(infos)); err != nil {
+		return err
+	}
+	for _, info := range infos {
+		writeCommandInfo(ctx, info)
+	}
+	return nil
+}
+
+// writeCommandInfo writes a command's entry in redis's COMMAND INFO layout:
+// name, arity, flags, first key, last key, key step, ACL categories, tips,
+// key specifications and subcommands. The last four are always empty.
+func writeCommandInfo(ctx *command.Context, info CommandInfo) {
+	cmd := info.Command
+	var flags []string
+	if cmd.HasFlag(command.FlagWrite) {
+		flags = append(flags, "write")
+	}
+	if cmd.HasFlag(command.FlagReadOnly) {
+		flags = append(flags, "readonly")
+	}
+	keys := cmd.KeyPositions()
+
+	ctx.ReplyArray(10)
+	ctx.Reply(strings.ToLower(info.Name))
+	ctx.ReplyInt(int64(cmd.Arity()))
+	ctx.ReplyArray(len(flags))
+	for _, flag := range flags {
+		ctx.ReplyStatus(flag)
+	}
+	ctx.ReplyInt(int64(keys.First))
+	ctx.ReplyInt(int64(keys.Last))
+	ctx.ReplyInt(int64(keys.Step))
+	for i := 0; i < 4; i++ {
+		ctx.ReplyArray(0)
+	}
+}
+
+// validClientString reports whether s may be used as a client name or
+// library attribute: printable ASCII without spaces
+func validClientString(s string) bool {
+	for _, r := range s {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// clientList formats the active connections in CLIENT LIST format
+func (s *Server) clientList() string {
+	s.mu.Lock()
+	sessions := make([]*command.Session, 0, len(s.conns))
+	for c := range s.conns {
+		sessions = append(sessions, c.session)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID() < sessions[j].ID()
+	})
+
+	var b strings.Builder
+	now := time.Now()
+	for _, session := range sessions {
+		libName, libVersion := session.Library()
+		fmt.Fprintf(&b, "id=%d addr=%s name=%s age=%d db=%d resp=%d lib-name=%s lib-ver=%s\n",
+			session.ID(), session.Addr(), session.Name(),
+			int64(now.Sub(session.Created()).Seconds()), session.DB(), session.Protocol(),
+			libName, libVersion)
+	}
+	return b.String()
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+	"github.com/aakash-a-dev/Goluxis/pkg/glob"
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+)
+
+// configParam is a Server option exposed to CONFIG GET and CONFIG SET
+type configParam struct {
+	name string
+	get  func() string
+	// set validates a new value and returns the change to make, which is
+	// applied under configMu. It is nil for options that cannot be changed
+	// while serving.
+	set func(value string) (func(), error)
+}
+
+// liveConfig reads an option that CONFIG SET can change while serving
+func liveConfig[T any](s *Server, option *T) T {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+
+	return *option
+}
+
+// configParams lists the options served by CONFIG, sorted by name. Redis's
+// parameter names and units are used where redis has an equivalent.
+// MaxLineLength and MaxBulkLength apply to connections accepted after they
+// are changed; the other settable options apply at once.
+func (s *Server) configParams() []configParam {
+	return []configParam{
+		durationParam(s, "command-timeout", &s.CommandTimeout, time.Millisecond),
+		{name: "databases", get: func() string { return strconv.Itoa(s.Databases) }},
+		intParam(s, "max-reply-bytes", &s.MaxReplyBytes, 0),
+		intParam(s, "maxclients", &s.MaxClients, 0),
+		intParam(s, "proto-inline-max-size", &s.MaxLineLength, resp.DefaultMaxLineLength),
+		intParam(s, "proto-max-bulk-len", &s.MaxBulkLength, resp.DefaultMaxBulkLength),
+		slowLogThresholdParam(s),
+		intParam(s, "slowlog-max-len", &s.SlowLogMaxLen, 0),
+		durationParam(s, "timeout", &s.IdleTimeout, time.Second),
+	}
+}
+
+// intParam exposes a non-negative integer option. An option that uses def
+// when zero reports def.
+func intParam[T int | int64](s *Server, name string, option *T, def T) configParam {
+	return configParam{
+		name: name,
+		get: func() string {
+			n := liveConfig(s, option)
+			if n == 0 {
+				n = def
+			}
+			return strconv.FormatInt(int64(n), 10)
+		},
+		set: func(value string) (func(), error) {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 || int64(T(n)) != n {
+				return nil, configSetError(name, "argument couldn't be parsed into an integer")
+			}
+			return func() { *option = T(n) }, nil
+		},
+	}
+}
+
+// durationParam exposes a non-negative duration option as a whole number
+// of unit
+func durationParam(s *Server, name string, option *time.Duration, unit time.Duration) configParam {
+	return configParam{
+		name: name,
+		get: func() string {
+			return strconv.FormatInt(int64(liveConfig(s, option)/unit), 10)
+		},
+		set: func(value string) (func(), error) {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 || n > math.MaxInt64/int64(unit) {
+				return nil, configSetError(name, "argument couldn't be parsed into an integer")
+			}
+			return func() { *option = time.Duration(n) * unit }, nil
+		},
+	}
+}
+
+// slowLogThresholdParam exposes SlowLogThreshold as redis's
+// slowlog-log-slower-than: microseconds, with -1 disabling the slow log and
+// 0 logging every command
+func slowLogThresholdParam(s *Server) configParam {
+	const name = "slowlog-log-slower-than"
+	return configParam{
+		name: name,
+		get: func() string {
+			threshold := liveConfig(s, &s.SlowLogThreshold)
+			if threshold <= 0 {
+				return "-1"
+			}
+			return strconv.FormatInt(threshold.Microseconds(), 10)
+		},
+		set: func(value string) (func(), error) {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n > math.MaxInt64/int64(time.Microsecond) {
+				return nil, configSetError(name, "argument couldn't be parsed into an integer")
+			}
+			threshold := time.Duration(n) * time.Microsecond
+			switch {
+			case n < 0:
+				threshold = 0
+			case n == 0:
+				threshold = time.Nanosecond
+			}
+			return func() { s.SlowLogThreshold = threshold }, nil
+		},
+	}
+}
+
+// configSetError is redis's error for a CONFIG SET of param that failed
+func configSetError(param, reason string) error {
+	return fmt.Errorf("ERR CONFIG SET failed (possibly related to argument '%s') - %s", param, reason)
+}
+
+// config implements CONFIG GET pattern [pattern ...] and
+// CONFIG SET parameter value [parameter value ...]
+func (s *Server) config(ctx *command.Context) error {
+	sub := strings.ToUpper(ctx.Args[1])
+	switch {
+	case sub == "GET" && len(ctx.Args) >= 3:
+		var matched []configParam
+		for _, param := range s.configParams() {
+			for _, pattern := range ctx.Args[2:] {
+				if glob.MatchNoCase(pattern, param.name) {
+					matched = append(matched, param)
+					break
+				}
+			}
+		}
+
+		if err := ctx.ReplyMap(len(matched)); err != nil {
+			return err
+		}
+		for _, param := range matched {
+			if err := ctx.Reply(param.name); err != nil {
+				return err
+			}
+			if err := ctx.Reply(param.get()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case sub == "SET" && len(ctx.Args) >= 4 && len(ctx.Args)%2 == 0:
+		params := make(map[string]configParam)
+		for _, param := range s.configParams() {
+			params[param.name] = param
+		}
+
+		// Every value is checked before any is applied
+		var changes []func()
+		seen := make(map[string]bool)
+		for i := 2; i < len(ctx.Args); i += 2 {
+			name := strings.ToLower(ctx.Args[i])
+			param, ok := params[name]
+			switch {
+			case !ok:
+				return fmt.Errorf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", ctx.Args[i])
+			case seen[name]:
+				return configSetError(name, "duplicate parameter")
+			case param.set == nil:
+				return configSetError(name, "can't set immutable config")
+			}
+			seen[name] = true
+
+			change, err := param.set(ctx.Args[i+1])
+			if err != nil {
+				return err
+			}
+			changes = append(changes, change)
+		}
+
+		s.configMu.Lock()
+		for _, change := range changes {
+			change()
+		}
+		s.configMu.Unlock()
+		return ctx.ReplyStatus("OK")
+
+	default:
+		return fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try CONFIG HELP.", ctx.Args[1])
+	}
+}
@@ -0,0 +1,713 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+)
+
+// conn is a single client connection. It implements command.RedisConn,
+// replying through its embedded replier.
+type conn struct {
+	replier
+	srv      *Server
+	rwc      io.ReadWriteCloser // usually a net.Conn
+	reader   *resp.Reader
+	session  *command.Session
+	pipeline *pipeline       // nil unless ConcurrentCommands allows it
+	warned   map[string]bool // deprecated commands already logged, under mu
+	busy     bool
+	budget   float64   // commands left under PerConnCommandLimit
+	refill   time.Time // when budget was last refilled
+	strikes  int       // consecutive commands rejected over the limit
+	mu       sync.Mutex
+
+	// Push frames waiting to be written, see WritePush
+	pushes     [][]byte
+	queued     int64     // bytes in pushes and being written
+	softSince  time.Time // when queued went over the soft output limit
+	delivering bool      // deliverPushes is running
+	pushErr    error     // pushes are refused after a failure
+
+	// wmu serializes writes made from other goroutines, such as the
+	// MONITOR feed and push frames, with the replies of the read loop
+	wmu sync.Mutex
+
+	closed    chan struct{} // closed by close
+	closeOnce sync.Once
+}
+
+// newConn wraps a connection from the client at addr for serving
+func newConn(srv *Server, rwc io.ReadWriteCloser, addr string) *conn {
+	c := &conn{
+		srv:     srv,
+		rwc:     rwc,
+		reader:  resp.NewReader(rwc),
+		session: command.NewSession(srv.nextConnID(), addr),
+		closed:  make(chan struct{}),
+	}
+	c.replier = replier{c: c, writer: resp.NewWriter(rwc)}
+	if srv.ConcurrentCommands > 1 {
+		c.pipeline = newPipeline(srv.ConcurrentCommands)
+	}
+	c.reader.SetMaxLineLength(liveConfig(srv, &srv.MaxLineLength))
+	c.reader.SetMaxBulkLength(liveConfig(srv, &srv.MaxBulkLength))
+	c.reader.SetMaxAggregateLength(srv.MaxAggregateLength)
+	c.reader.CountBytes(srv.CountNetworkBytes)
+	c.writer.CountBytes(srv.CountNetworkBytes)
+	return c
+}
+
+// replier writes the replies of one command at a time. A connection
+// replies through its own, which writes to the client; commands run
+// concurrently each get one writing to a buffer, see pipeline.
+type replier struct {
+	c       *conn
+	writer  *resp.Writer
+	attrs   map[string]interface{}
+	replied bool
+	closing bool
+}
+
+// w returns the writer set up for the session's negotiated protocol.
+// Pending reply attributes are written first so that they precede the reply.
+func (r *replier) w() *resp.Writer {
+	r.replied = true
+	r.writer.SetProtocol(r.c.session.Protocol())
+	if r.attrs != nil {
+		attrs := r.attrs
+		r.attrs = nil
+		if err := r.writer.WriteAttributes(attrs); err != nil {
+			r.c.srv.Logger.Printf("Failed to write reply attributes: %v", err)
+		}
+	}
+	return r.writer
+}
+
+// SetAttribute records an attribute to send before the next reply
+func (r *replier) SetAttribute(key string, value interface{}) {
+	if r.attrs == nil {
+		r.attrs = make(map[string]interface{})
+	}
+	r.attrs[key] = value
+}
+
+// WriteString writes a bulk string reply
+func (r *replier) WriteString(s string) error {
+	return r.w().WriteBulkString(s)
+}
+
+// WriteStatus writes a simple string reply
+func (r *replier) WriteStatus(s string) error {
+	return r.w().WriteSimpleString(s)
+}
+
+// WriteInt writes an integer reply
+func (r *replier) WriteInt(i int64) error {
+	return r.w().WriteInteger(i)
+}
+
+// WriteFloat writes a double reply
+func (r *replier) WriteFloat(f float64) error {
+	return r.w().WriteDouble(f)
+}
+
+// WriteBool writes a boolean reply
+func (r *replier) WriteBool(b bool) error {
+	return r.w().WriteBoolean(b)
+}
+
+// WriteArray writes an array reply header
+func (r *replier) WriteArray(length int) error {
+	return r.w().WriteArray(length)
+}
+
+// WriteMap writes a map reply header
+func (r *replier) WriteMap(length int) error {
+	return r.w().WriteMap(length)
+}
+
+// WriteStruct writes a struct as a map reply
+func (r *replier) WriteStruct(v interface{}) error {
+	return r.w().WriteStruct(v)
+}
+
+// WriteJSON writes a JSON document as the equivalent RESP value
+func (r *replier) WriteJSON(data []byte) error {
+	return r.w().WriteJSON(data)
+}
+
+// WriteRaw writes a pre-encoded reply
+func (r *replier) WriteRaw(p []byte) error {
+	return r.w().WriteRaw(p)
+}
+
+// WriteSet writes a set reply header
+func (r *replier) WriteSet(length int) error {
+	return r.w().WriteSet(length)
+}
+
+// WriteNull writes a null reply
+func (r *replier) WriteNull() error {
+	return r.w().WriteNull()
+}
+
+// WriteError writes an error reply
+func (r *replier) WriteError(err error) error {
+	return r.w().WriteError(err)
+}
+
+// WriteCompressed writes a payload, compressing it if the client negotiated
+// compression and it is at least the server's compression threshold
+func (r *replier) WriteCompressed(p []byte) error {
+	if !r.c.session.Compression() || len(p) < r.c.srv.CompressionThreshold {
+		return r.w().WriteBulkString(string(p))
+	}
+	return r.w().WriteCompressed(p)
+}
+
+// WriteVerbatim writes text tagged with a three character format
+func (r *replier) WriteVerbatim(format, s string) error {
+	return r.w().WriteVerbatim(format, s)
+}
+
+// WriteValue writes a Go value as the matching RESP type
+func (r *replier) WriteValue(v interface{}) error {
+	return r.w().WriteValue(v)
+}
+
+// BeginStream starts a streamed array reply
+func (r *replier) BeginStream() error {
+	return r.w().BeginStreamArray()
+}
+
+// EndStream finishes a streamed array reply
+func (r *replier) EndStream() error {
+	return r.w().EndStream()
+}
+
+// CloseAfterReply marks the connection to be closed after the current command
+func (r *replier) CloseAfterReply() {
+	r.closing = true
+}
+
+// Flush is a no-op since the Writer flushes after each write
+func (r *replier) Flush() error {
+	return nil
+}
+
+// WritePush writes a RESP3 push frame. It may be called from any goroutine
+// and does not wait for the client: frames are queued and written in the
+// background, and while a command is executing they are held until its
+// reply is complete, so they never land inside a reply. A client whose
+// queue breaks its OutputBufferLimit is disconnected. RESP2 clients only
+// accept pushes while subscribed to pub/sub channels, and receive them as
+// arrays.
+func (c *conn) WritePush(kind string, elements ...interface{}) error {
+	protocol := c.session.Protocol()
+	if protocol < resp.RESP3 && c.session.Subscriptions() == 0 {
+		return resp.ErrPushUnsupported
+	}
+	frame, err := encodePush(protocol, kind, elements)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pushErr != nil {
+		return c.pushErr
+	}
+	c.pushes = append(c.pushes, frame)
+	c.queued += int64(len(frame))
+	if c.outputLimitExceeded(time.Now()) {
+		c.srv.Logger.Printf("Disconnecting %s: output buffer limit exceeded with %d bytes queued", c.session.Addr(), c.queued)
+		c.pushErr = ErrOutputBufferLimit
+		c.pushes = nil
+		c.close()
+		return c.pushErr
+	}
+
+	if !c.busy && !c.delivering {
+		c.delivering = true
+		go c.deliverPushes()
+	}
+	return nil
+}
+
+// deliverPushes writes queued push frames until the queue is empty or a
+// command starts, in which case end delivers the rest. The caller must
+// have set c.delivering.
+func (c *conn) deliverPushes() {
+	for {
+		c.mu.Lock()
+		if c.busy || len(c.pushes) == 0 {
+			c.delivering = false
+			c.mu.Unlock()
+			return
+		}
+		frames := c.pushes
+		c.pushes = nil
+		// Take wmu before releasing mu so that begin waits for the write
+		c.wmu.Lock()
+		c.mu.Unlock()
+
+		var size int64
+		var err error
+		for _, frame := range frames {
+			size += int64(len(frame))
+			if err == nil {
+				_, err = c.writer.Write(frame)
+			}
+		}
+		if err == nil {
+			err = c.writer.Flush()
+		}
+		c.wmu.Unlock()
+
+		c.mu.Lock()
+		c.queued -= size
+		c.outputLimitExceeded(time.Now())
+		if err != nil && c.pushErr == nil {
+			c.pushErr = err
+			c.pushes = nil
+			c.close()
+		}
+		c.mu.Unlock()
+	}
+}
+
+// RawReader returns the reader commands are read from
+func (c *conn) RawReader() *resp.Reader {
+	return c.reader
+}
+
+// begin marks the connection as executing a command. It returns false if
+// the server is shutting down and no new command should be started.
+func (c *conn) begin() bool {
+	c.mu.Lock()
+	if c.srv.isClosed() {
+		c.mu.Unlock()
+		return false
+	}
+	c.busy = true
+	c.mu.Unlock()
+
+	// Wait for push frames being written to finish before replying
+	c.wmu.Lock()
+	c.wmu.Unlock()
+	return true
+}
+
+// end marks the connection as idle again and writes the push frames
+// queued while the command was executing before the next command is read
+func (c *conn) end() {
+	c.mu.Lock()
+	c.busy = false
+	deliver := len(c.pushes) > 0 && !c.delivering
+	if deliver {
+		c.delivering = true
+	}
+	c.mu.Unlock()
+
+	if deliver {
+		c.deliverPushes()
+	}
+}
+
+// close closes the network connection. It may be called more than once.
+func (c *conn) close() {
+	c.closeOnce.Do(func() {
+		c.rwc.Close()
+		close(c.closed)
+	})
+}
+
+// closeIfIdle closes the connection unless it is executing a command
+func (c *conn) closeIfIdle() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.busy {
+		c.close()
+	}
+}
+
+// readDeadliner is implemented by connections whose reads can time out,
+// such as net.Conn
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// writeDeadliner is implemented by connections whose writes can time out,
+// such as net.Conn
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// setIdleDeadline sets the deadline for the client's next command from
+// IdleTimeout, if the connection supports deadlines. Subscribers and
+// monitors wait for data rather than send commands, so they have none.
+func (c *conn) setIdleDeadline() {
+	var deadline time.Time
+	timeout := liveConfig(c.srv, &c.srv.IdleTimeout)
+	if timeout > 0 && c.session.Subscriptions() == 0 && !c.session.Monitoring() {
+		deadline = time.Now().Add(timeout)
+	}
+	if d, ok := c.rwc.(readDeadliner); ok {
+		d.SetReadDeadline(deadline)
+	}
+}
+
+// readClosed handles a client that has closed its side of the connection.
+// A TCP client may shut down writing (sending FIN) and keep reading, so
+// push frames still queued for it are written first. Replies need no such
+// care since they are written before the next command is read. Subscribers
+// and monitors keep receiving until the server shuts down or a write to
+// them fails; a subscriber that closed the connection completely is thus
+// only noticed once a message to it cannot be written.
+func (c *conn) readClosed() {
+	c.pipeline.wait()
+	if !c.begin() {
+		return
+	}
+	c.end()
+
+	if c.session.Subscriptions() == 0 && !c.session.Monitoring() {
+		return
+	}
+	select {
+	case <-c.closed:
+	case <-c.srv.done:
+	}
+}
+
+// serve reads and executes commands until the client disconnects or the
+// server shuts down
+func (c *conn) serve() {
+	defer c.srv.untrackConn(c)
+	defer c.close()
+	defer c.pipeline.wait()
+
+	for {
+		// Read command
+		c.setIdleDeadline()
+		name, raw, err := c.reader.ParseCommand()
+		invalid := errors.Is(err, resp.ErrInvalidCommand)
+		if err != nil && !invalid {
+			var netErr net.Error
+			idle := errors.As(err, &netErr) && netErr.Timeout()
+			if err != io.EOF && !idle && !c.srv.isClosed() {
+				c.srv.Logger.Printf("Error reading command: %v", err)
+			}
+			if err == io.EOF {
+				c.readClosed()
+			}
+			return
+		}
+
+		if !c.srv.waitPause() || !c.begin() {
+			return
+		}
+		keepOpen := true
+		if invalid {
+			c.reject(err)
+		} else {
+			args := make([]string, 1+len(raw))
+			args[0] = name
+			for i, arg := range raw {
+				args[i+1] = string(arg)
+			}
+			keepOpen = c.dispatch(args)
+		}
+		c.end()
+
+		if !keepOpen || c.srv.isClosed() {
+			return
+		}
+	}
+}
+
+// reject replies to a frame that is not a valid command
+func (c *conn) reject(err error) {
+	if c.session.Monitoring() {
+		c.wmu.Lock()
+		defer c.wmu.Unlock()
+	}
+	c.reader.TakeAttributes()
+	c.WriteError(fmt.Errorf("ERR Protocol error: %v", err))
+}
+
+// writeHelp replies to CMD HELP with the command's usage line and
+// description
+func (c *conn) writeHelp(cmd *command.Command, name string) {
+	lines := []string{cmd.Usage(name)}
+	if cmd.Description != "" {
+		lines = append(lines, cmd.Description)
+	}
+	c.WriteArray(len(lines))
+	for _, line := range lines {
+		c.WriteStatus(line)
+	}
+}
+
+// dispatch executes a single command read from the client, given as its
+// name followed by its arguments. It returns false if the connection must
+// be closed, either because the reply is incomplete or because the handler
+// asked for it with CloseAfterReply. Commands that may run concurrently
+// are handed to the pipeline; any other waits for them to finish first.
+func (c *conn) dispatch(args []string) bool {
+	traceID := c.traceID()
+	cmdName := args[0]
+
+	var cmd *command.Command
+	var err error
+	allowed := c.allowCommand()
+	if allowed {
+		cmd, err = c.srv.lookup(cmdName, args[1:])
+	}
+	if allowed && err == nil && c.pipeline != nil && cmd.HasFlag(command.FlagConcurrent) &&
+		cmd.CheckArity(len(args)-1) && !cmd.WantsHelp(args) && !c.session.Monitoring() {
+		c.pipeline.start(c, cmd, args, traceID)
+		return true
+	}
+
+	// Replies written from here on follow those of the commands in flight
+	c.pipeline.wait()
+
+	if c.session.Monitoring() {
+		c.wmu.Lock()
+		defer c.wmu.Unlock()
+	}
+
+	if !allowed {
+		c.WriteError(ErrTooManyRequests)
+		if limit := c.srv.MaxLimitViolations; limit > 0 && c.strikes >= limit {
+			c.srv.Logger.Printf("[trace %s] Closing connection %s after %d commands over the limit",
+				traceID, c.session.Addr(), c.strikes)
+			return false
+		}
+		return true
+	}
+
+	if err != nil {
+		if errors.Is(err, command.ErrCommandNotFound) {
+			err = c.srv.unknownCommand(cmdName, args[1:])
+		}
+		c.WriteError(err)
+		return true
+	}
+
+	if cmd.WantsHelp(args) {
+		c.writeHelp(cmd, cmdName)
+		return true
+	}
+	if !cmd.CheckArity(len(args) - 1) {
+		c.WriteError(cmd.ArityError(cmdName))
+		return true
+	}
+
+	return c.run(&c.replier, c, cmd, args, traceID)
+}
+
+// run executes a command that passed the arity check, replying through r,
+// which rc exposes to the handler as ctx.Conn. It returns false if the
+// connection must be closed, as dispatch does.
+func (c *conn) run(r *replier, rc command.RedisConn, cmd *command.Command, args []string, traceID string) bool {
+	c.session.SetLastCommand(cmd.Name)
+	c.srv.feedMonitors(c, args)
+
+	// Create context
+	ctx := &command.Context{
+		Args:    args,
+		Conn:    rc,
+		Session: c.session,
+		TraceID: traceID,
+	}
+	if err := ctx.BindArgs(cmd.ArgSpec); err != nil {
+		r.WriteError(err)
+		return true
+	}
+
+	if cmd.Deprecated != "" {
+		c.warnDeprecated(r, cmd, traceID)
+	}
+
+	if timeout := liveConfig(c.srv, &c.srv.CommandTimeout); timeout > 0 {
+		cctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		ctx.SetContext(cctx)
+	}
+
+	// Execute command
+	r.replied = false
+	r.writer.ResetReply()
+	maxReply := liveConfig(c.srv, &c.srv.MaxReplyBytes)
+	r.writer.SetReplyLimit(int64(maxReply))
+	start := time.Now()
+	err := c.call(cmd, ctx)
+	elapsed := time.Since(start)
+	r.writer.SetReplyLimit(0)
+	r.attrs = nil
+	if err != nil && !r.replied && errors.Is(err, context.DeadlineExceeded) {
+		err = ErrCommandTimeout
+	}
+	if r.writer.ReplyTooLarge() {
+		// Handlers may ignore the failed write, so the writer is asked
+		// rather than err. A reply nothing was sent of can be replaced.
+		err = ErrReplyTooLarge
+		if !r.writer.ReplySent() {
+			c.srv.Logger.Printf("[trace %s] Discarding %s reply over %d bytes", traceID, cmd.Name, maxReply)
+			r.writer.ResetReply()
+			r.replied = false
+		}
+	}
+
+	c.srv.recordCommand(cmd.Name, elapsed, err)
+	c.srv.recordSlow(c, args, elapsed)
+	if c.srv.Observer != nil {
+		c.srv.Observer.ObserveCommand(CommandEvent{
+			TraceID:  traceID,
+			Command:  cmd.Name,
+			Args:     args,
+			ClientID: c.session.ID(),
+			Duration: elapsed,
+			Err:      err,
+		})
+	}
+
+	// Once a reply has been started, writing an error would land in the
+	// middle of its frame, so the only safe option is to drop the client
+	if r.replied && (err != nil || r.writer.Streaming()) {
+		if err == nil {
+			err = errors.New("stream not ended")
+		}
+		if !errors.Is(err, command.ErrReplyAborted) {
+			c.srv.Logger.Printf("[trace %s] Closing connection after incomplete %s reply: %v", traceID, cmd.Name, err)
+		}
+		return false
+	}
+
+	if err != nil {
+		r.WriteError(err)
+	}
+	return !r.closing
+}
+
+// allowCommand takes one command from the connection's budget, refilled at
+// PerConnCommandLimit per second. It reports false if the budget is spent.
+func (c *conn) allowCommand() bool {
+	limit := float64(c.srv.PerConnCommandLimit)
+	if limit <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	if c.refill.IsZero() {
+		c.budget = limit
+	} else {
+		c.budget = min(limit, c.budget+now.Sub(c.refill).Seconds()*limit)
+	}
+	c.refill = now
+
+	if c.budget < 1 {
+		c.strikes++
+		return false
+	}
+	c.budget--
+	c.strikes = 0
+	return true
+}
+
+// warnDeprecated logs the first use of a deprecated command on the
+// connection and, if enabled, announces the deprecation in the reply
+func (c *conn) warnDeprecated(r *replier, cmd *command.Command, traceID string) {
+	c.mu.Lock()
+	first := !c.warned[cmd.Name]
+	if first {
+		if c.warned == nil {
+			c.warned = make(map[string]bool)
+		}
+		c.warned[cmd.Name] = true
+	}
+	c.mu.Unlock()
+
+	if first {
+		c.srv.Logger.Printf("[trace %s] Client %s called deprecated command %s: %s",
+			traceID, c.session.Addr(), cmd.Name, cmd.Deprecated)
+	}
+	if c.srv.AnnounceDeprecations {
+		r.SetAttribute("deprecated", cmd.Deprecated)
+	}
+}
+
+// call executes a command, recovering from a handler panic so that it
+// only fails the command instead of the connection or the server
+func (c *conn) call(cmd *command.Command, ctx *command.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.srv.Logger.Printf("[trace %s] Panic in handler for %s: %v\n%s", ctx.TraceID, cmd.Name, r, debug.Stack())
+			c.srv.recordError(fmt.Errorf("panic in handler for %s: %v", cmd.Name, r))
+			err = ErrInternal
+		}
+	}()
+
+	return c.srv.execute(cmd, ctx)
+}
+
+// Connections implement every optional reply capability of command.Context
+var _ interface {
+	command.RedisConn
+	command.StatusConn
+	command.FloatConn
+	command.BoolConn
+	command.MapConn
+	command.SetConn
+	command.VerbatimConn
+	command.CompressedConn
+	command.ValueConn
+	command.StructConn
+	command.JSONConn
+	command.RawReplyConn
+	command.StreamConn
+	command.AttributeConn
+	command.ClosingConn
+	command.PushConn
+	command.RawConn
+} = (*conn)(nil)
+
+// discardConn is a command.RedisConn that drops every reply. It is used
+// when replaying commands that have no client.
+type discardConn struct{}
+
+func (discardConn) WriteString(string) error               { return nil }
+func (discardConn) WriteStatus(string) error               { return nil }
+func (discardConn) WriteInt(int64) error                   { return nil }
+func (discardConn) WriteFloat(float64) error               { return nil }
+func (discardConn) WriteBool(bool) error                   { return nil }
+func (discardConn) WriteArray(int) error                   { return nil }
+func (discardConn) WriteMap(int) error                     { return nil }
+func (discardConn) WriteSet(int) error                     { return nil }
+func (discardConn) WriteNull() error                       { return nil }
+func (discardConn) WriteError(error) error                 { return nil }
+func (discardConn) WriteCompressed([]byte) error           { return nil }
+func (discardConn) WriteVerbatim(string, string) error     { return nil }
+func (discardConn) WritePush(string, ...interface{}) error { return nil }
+func (discardConn) WriteValue(interface{}) error           { return nil }
+func (discardConn) WriteStruct(interface{}) error          { return nil }
+func (discardConn) WriteJSON([]byte) error                 { return nil }
+func (discardConn) WriteRaw([]byte) error                  { return nil }
+func (discardConn) BeginStream() error                     { return nil }
+func (discardConn) EndStream() error                       { return nil }
+func (discardConn) SetAttribute(string, interface{})       {}
+func (discardConn) CloseAfterReply()                       {}
+func (discardConn) Flush() error                           { return nil }
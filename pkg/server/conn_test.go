@@ -0,0 +1,31 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/client"
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+)
+
+func TestHandlerPanic(t *testing.T) {
+	ext := command.NewExtension("test")
+	panicCmd := command.New("PANIC")
+	panicCmd.Handler = func(ctx *command.Context) error {
+		panic("boom")
+	}
+	ext.AddCommand(panicCmd)
+	c := dial(t, startServer(t, New(ext)))
+
+	_, err := c.Do("PANIC")
+	if replyErr, ok := err.(client.ReplyError); !ok || replyErr.Error() != ErrInternal.Error() {
+		t.Fatalf("PANIC error = %v, want %v", err, ErrInternal)
+	}
+
+	reply, err := c.Do("PING")
+	if err != nil {
+		t.Fatalf("PING after panic: %v", err)
+	}
+	if s, _ := reply.Str(); s != "PONG" {
+		t.Fatalf("PING after panic = %q, want PONG", s)
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+)
+
+// addDebugCommands adds DEBUG to the built-in commands if
+// EnableDebugCommands is set. It runs when serving starts rather than in
+// New so that the flag can be set after the server is created.
+func (s *Server) addDebugCommands() {
+	if !s.EnableDebugCommands {
+		return
+	}
+	if _, err := s.builtins.GetCommand("DEBUG"); err == nil {
+		return
+	}
+
+	debug := command.New("DEBUG")
+	debug.Description = "Debugging commands, enabled by EnableDebugCommands"
+	debug.MinArgs = 1
+	debug.HandlerCtx = s.debug
+	s.builtins.AddCommand(debug)
+}
+
+// debug implements DEBUG SLEEP seconds
+func (s *Server) debug(cctx context.Context, ctx *command.Context) error {
+	sub := strings.ToUpper(ctx.Args[1])
+	switch {
+	case sub == "SLEEP" && len(ctx.Args) == 3:
+		seconds, err := strconv.ParseFloat(ctx.Args[2], 64)
+		if err != nil || seconds < 0 || seconds > math.MaxInt64/float64(time.Second) {
+			return errors.New("ERR value is not a valid float")
+		}
+
+		// Wake up early if the command's deadline passes
+		timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return ctx.ReplyStatus("OK")
+		case <-cctx.Done():
+			return cctx.Err()
+		}
+
+	default:
+		return fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try DEBUG HELP.", ctx.Args[1])
+	}
+}
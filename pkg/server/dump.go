@@ -0,0 +1,67 @@
+package server
+
+import (
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// DumpState logs a snapshot of the server for quick triage: the number of
+// goroutines and connections, the age and last command of each
+// connection, and the size of every registered keyspace that is a Sizer.
+// It is also run on DumpSignal, if set.
+func (s *Server) DumpState() {
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].session.ID() < conns[j].session.ID()
+	})
+
+	now := time.Now()
+	s.Logger.Printf("State dump: %d goroutines, %d connections", runtime.NumGoroutine(), len(conns))
+	for _, c := range conns {
+		session := c.session
+		age := now.Sub(session.Created()).Truncate(time.Second)
+		name, at := session.LastCommand()
+		if name == "" {
+			s.Logger.Printf("  client id=%d addr=%s age=%v last-cmd=none", session.ID(), session.Addr(), age)
+			continue
+		}
+		s.Logger.Printf("  client id=%d addr=%s age=%v last-cmd=%s (%v ago)",
+			session.ID(), session.Addr(), age, name, now.Sub(at).Truncate(time.Millisecond))
+	}
+	for _, ks := range s.snapshotKeyspaces() {
+		if sizer, ok := ks.keyspace.(Sizer); ok {
+			s.Logger.Printf("  keyspace %s: %d keys", ks.typeName, sizer.Len())
+		}
+	}
+}
+
+// watchDump calls DumpState on DumpSignal until the server shuts down, if
+// it is set
+func (s *Server) watchDump() {
+	if s.DumpSignal == nil {
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, s.DumpSignal)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				s.DumpState()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
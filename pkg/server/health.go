@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+)
+
+// healthStatus is the JSON body of the health endpoints
+type healthStatus struct {
+	Status      string     `json:"status"`
+	Connections int        `json:"connections"`
+	MaxClients  int        `json:"max_clients,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// EnableHealthHTTP starts an HTTP server on addr for liveness and readiness
+// probes, independent of the RESP listener. /healthz answers 200 while the
+// process is running. /readyz answers 200 once the Server has loaded its
+// persisted state and is accepting connections below MaxClients without
+// being paused, and 503 otherwise. Both report the number of active
+// connections and the last error logged by the Server. The HTTP server is
+// closed by Shutdown.
+func (s *Server) EnableHealthHTTP(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		s.writeHealth(w, true)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		s.writeHealth(w, s.ready.Load() && !s.atCapacity() && !s.Paused())
+	})
+
+	hs := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	s.mu.Lock()
+	s.health = hs
+	s.mu.Unlock()
+
+	go hs.Serve(listener)
+	return nil
+}
+
+// writeHealth writes the health status with 200 if ok and 503 otherwise
+func (s *Server) writeHealth(w http.ResponseWriter, ok bool) {
+	status := healthStatus{
+		Status:      "ok",
+		Connections: s.connCount(),
+		MaxClients:  liveConfig(s, &s.MaxClients),
+	}
+
+	s.errMu.Lock()
+	if s.lastErr != nil {
+		at := s.lastErrAt
+		status.LastError = s.lastErr.Error()
+		status.LastErrorAt = &at
+	}
+	s.errMu.Unlock()
+
+	code := http.StatusOK
+	if !ok {
+		status.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+// recordError remembers err as the last error for the health endpoints
+func (s *Server) recordError(err error) {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+
+	s.lastErr = err
+	s.lastErrAt = time.Now()
+}
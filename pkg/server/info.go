@@ -0,0 +1,173 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+	"github.com/aakash-a-dev/Goluxis/pkg/store"
+)
+
+// commandStat accumulates the calls of one command
+type commandStat struct {
+	calls  int64
+	usec   int64
+	failed int64
+}
+
+// infoSections lists the INFO sections in output order. Those marked
+// default are reported when INFO is called without arguments.
+var infoSections = []struct {
+	name        string
+	defaultShow bool
+	write       func(s *Server, b *strings.Builder)
+}{
+	{"server", true, (*Server).infoServer},
+	{"clients", true, (*Server).infoClients},
+	{"memory", true, (*Server).infoMemory},
+	{"stats", true, (*Server).infoStats},
+	{"commandstats", false, (*Server).infoCommandStats},
+}
+
+// recordCommand counts a command executed for a client
+func (s *Server) recordCommand(name string, d time.Duration, err error) {
+	s.commandsProcessed.Add(1)
+
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	stat, exists := s.cmdStats[name]
+	if !exists {
+		stat = &commandStat{}
+		s.cmdStats[name] = stat
+	}
+	stat.calls++
+	stat.usec += d.Microseconds()
+	if err != nil {
+		stat.failed++
+	}
+}
+
+// info implements INFO [section ...]. Sections are matched
+// case-insensitively; "all" and "everything" select every section and
+// "default" the sections shown without arguments.
+func (s *Server) info(ctx *command.Context) error {
+	wanted := make(map[string]bool)
+	for _, arg := range ctx.Args[1:] {
+		wanted[strings.ToLower(arg)] = true
+	}
+	all := wanted["all"] || wanted["everything"]
+	defaults := len(wanted) == 0 || wanted["default"]
+
+	var b strings.Builder
+	for _, section := range infoSections {
+		if !all && !wanted[section.name] && !(defaults && section.defaultShow) {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "# %s\r\n", strings.ToUpper(section.name[:1])+section.name[1:])
+		section.write(s, &b)
+	}
+	return ctx.ReplyVerbatim("txt", b.String())
+}
+
+// infoField writes a key:value line
+func infoField(b *strings.Builder, key string, value interface{}) {
+	fmt.Fprintf(b, "%s:%v\r\n", key, value)
+}
+
+// infoServer writes the server section: version, process and uptime
+func (s *Server) infoServer(b *strings.Builder) {
+	uptime := time.Since(s.started)
+	infoField(b, "goluxis_version", Version)
+	infoField(b, "redis_mode", "standalone")
+	infoField(b, "os", runtime.GOOS+" "+runtime.GOARCH)
+	infoField(b, "go_version", runtime.Version())
+	infoField(b, "process_id", os.Getpid())
+
+	s.mu.Lock()
+	if s.listener != nil {
+		if addr, ok := s.listener.Addr().(*net.TCPAddr); ok {
+			infoField(b, "tcp_port", addr.Port)
+		}
+	}
+	s.mu.Unlock()
+
+	infoField(b, "uptime_in_seconds", int64(uptime.Seconds()))
+	infoField(b, "uptime_in_days", int64(uptime.Hours()/24))
+}
+
+// infoClients writes the clients section
+func (s *Server) infoClients(b *strings.Builder) {
+	infoField(b, "connected_clients", s.connCount())
+	infoField(b, "maxclients", liveConfig(s, &s.MaxClients))
+}
+
+// infoMemory writes the memory section from the Go heap statistics
+func (s *Server) infoMemory(b *strings.Builder) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	infoField(b, "used_memory", m.HeapAlloc)
+	infoField(b, "used_memory_human", humanBytes(m.HeapAlloc))
+	infoField(b, "used_memory_sys", m.Sys)
+	infoField(b, "used_memory_sys_human", humanBytes(m.Sys))
+}
+
+// infoStats writes the stats section
+func (s *Server) infoStats(b *strings.Builder) {
+	infoField(b, "total_connections_received", s.lastID.Load())
+	infoField(b, "total_commands_processed", s.commandsProcessed.Load())
+	infoField(b, "rejected_connections", s.rejectedConns.Load())
+
+	input, output := s.netBytes()
+	infoField(b, "total_net_input_bytes", input)
+	infoField(b, "total_net_output_bytes", output)
+}
+
+// netBytes returns the bytes read from and written to clients so far,
+// counted while CountNetworkBytes was set
+func (s *Server) netBytes() (input, output int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	input, output = s.netInputBytes.Load(), s.netOutputBytes.Load()
+	for c := range s.conns {
+		input += c.reader.BytesRead()
+		output += c.writer.BytesWritten()
+	}
+	return input, output
+}
+
+// infoCommandStats writes one cmdstat line per command called so far
+func (s *Server) infoCommandStats(b *strings.Builder) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	for _, name := range store.SortedKeys(s.cmdStats) {
+		stat := s.cmdStats[name]
+		fmt.Fprintf(b, "cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,failed_calls=%d\r\n",
+			strings.ToLower(name), stat.calls, stat.usec, float64(stat.usec)/float64(stat.calls), stat.failed)
+	}
+}
+
+// humanBytes formats n the way redis reports memory, such as 1.50M
+func humanBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n) / unit
+	suffix := "KMGTP"
+	i := 0
+	for value >= unit && i < len(suffix)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.2f%c", value, suffix[i])
+}
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+	"github.com/aakash-a-dev/Goluxis/pkg/glob"
+	"github.com/aakash-a-dev/Goluxis/pkg/store"
+)
+
+// Keyspace is a collection of keys owned by an extension. Registering
+// keyspaces with the Server gives extensions redis's generic key commands,
+// TYPE, DEL, EXISTS and KEYS, across all of them.
+type Keyspace interface {
+	// Exists reports whether key is present
+	Exists(key string) bool
+	// Delete removes key, reporting whether it was present
+	Delete(key string) bool
+	// Keys returns the keys matching a redis-style glob pattern
+	Keys(pattern string) []string
+}
+
+// AccessTracker is implemented by keyspaces that record how recently and
+// how often their keys are read, for OBJECT FREQ and OBJECT IDLETIME
+type AccessTracker interface {
+	// AccessInfo returns the access metadata of key, reporting false if
+	// it is not tracked
+	AccessInfo(key string) (store.AccessInfo, bool)
+}
+
+// Sizer is implemented by keyspaces that can count their keys, reported
+// by DumpState
+type Sizer interface {
+	// Len returns the number of keys
+	Len() int
+}
+
+// StoreKeyspace adapts a store.Store to a Keyspace. It is an AccessTracker
+// for stores that track access, and a Sizer.
+func StoreKeyspace[V any](s *store.Store[V]) Keyspace {
+	return storeKeyspace[V]{s}
+}
+
+// storeKeyspace is the Keyspace returned by StoreKeyspace
+type storeKeyspace[V any] struct {
+	s *store.Store[V]
+}
+
+func (ks storeKeyspace[V]) Exists(key string) bool {
+	return ks.s.Exists(key)
+}
+
+func (ks storeKeyspace[V]) Delete(key string) bool {
+	return ks.s.Delete(key)
+}
+
+func (ks storeKeyspace[V]) Keys(pattern string) []string {
+	return MatchKeys(ks.s.Keys(), pattern)
+}
+
+func (ks storeKeyspace[V]) Len() int {
+	return ks.s.Len()
+}
+
+func (ks storeKeyspace[V]) AccessInfo(key string) (store.AccessInfo, bool) {
+	return ks.s.AccessInfo(key)
+}
+
+// MatchKeys returns the keys matching a redis-style glob pattern, for
+// implementing Keyspace.Keys
+func MatchKeys(keys []string, pattern string) []string {
+	if pattern == "*" {
+		return keys
+	}
+	matched := keys[:0:0]
+	for _, key := range keys {
+		if glob.Match(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}
+
+// namedKeyspace is a registered keyspace and the type name of its keys
+type namedKeyspace struct {
+	typeName string
+	keyspace Keyspace
+}
+
+// RegisterKeyspace makes the keys of ks visible to the built-in key
+// commands, which are served once a keyspace is registered. TYPE reports
+// typeName, such as "timeseries", for them. Keyspaces are consulted in
+// registration order, so a key present in several is reported with the
+// type of the first. It must be called before serving starts.
+func (s *Server) RegisterKeyspace(typeName string, ks Keyspace) {
+	s.keyspaceMu.Lock()
+	defer s.keyspaceMu.Unlock()
+
+	s.keyspaces = append(s.keyspaces, namedKeyspace{typeName: typeName, keyspace: ks})
+}
+
+// addKeyCommands adds TYPE, DEL, EXISTS, KEYS and OBJECT to the built-in commands
+// if a keyspace is registered. Like addDebugCommands it runs when serving
+// starts. Commands an extension serves itself are not replaced.
+func (s *Server) addKeyCommands() {
+	s.keyspaceMu.RLock()
+	registered := len(s.keyspaces) > 0
+	s.keyspaceMu.RUnlock()
+	if !registered {
+		return
+	}
+
+	typ := command.New("TYPE")
+	typ.Description = "Report the type of the value stored at a key"
+	typ.Flags = command.FlagReadOnly
+	typ.MinArgs = 1
+	typ.MaxArgs = 1
+	typ.Keys = &command.KeySpec{First: 1, Last: 1, Step: 1}
+	typ.Handler = s.typeCmd
+
+	del := command.New("DEL")
+	del.Description = "Delete keys from every registered keyspace"
+	del.Flags = command.FlagWrite
+	del.MinArgs = 1
+	del.Keys = &command.KeySpec{First: 1, Last: -1, Step: 1}
+	del.Handler = s.del
+
+	exists := command.New("EXISTS")
+	exists.Description = "Count the given keys present in a registered keyspace"
+	exists.Flags = command.FlagReadOnly
+	exists.MinArgs = 1
+	exists.Keys = &command.KeySpec{First: 1, Last: -1, Step: 1}
+	exists.Handler = s.exists
+
+	keys := command.New("KEYS")
+	keys.Description = "List the keys matching a pattern in every registered keyspace"
+	keys.Flags = command.FlagReadOnly
+	keys.MinArgs = 1
+	keys.MaxArgs = 1
+	keys.Handler = s.keys
+
+	object := command.New("OBJECT")
+	object.Description = "Report how often and how recently a key was read"
+	object.Flags = command.FlagReadOnly
+	object.MinArgs = 1
+	object.Keys = &command.KeySpec{First: 2, Last: 2, Step: 1}
+	object.Handler = s.object
+
+	s.extMu.Lock()
+	defer s.extMu.Unlock()
+	for _, cmd := range []*command.Command{typ, del, exists, keys, object} {
+		if s.servedByExtension(cmd.Name) {
+			continue
+		}
+		if _, err := s.builtins.GetCommand(cmd.Name); err == nil {
+			continue
+		}
+		s.builtins.AddCommand(cmd)
+	}
+}
+
+// servedByExtension reports whether a registered extension has a command
+// for name. Default handlers are not considered. The caller must hold
+// s.extMu.
+func (s *Server) servedByExtension(name string) bool {
+	for _, ext := range s.exts {
+		if _, err := ext.Resolve(name, nil); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotKeyspaces returns the registered keyspaces
+func (s *Server) snapshotKeyspaces() []namedKeyspace {
+	s.keyspaceMu.RLock()
+	defer s.keyspaceMu.RUnlock()
+
+	return s.keyspaces
+}
+
+// keyType returns the type name of the first keyspace holding key
+func (s *Server) keyType(key string) (string, bool) {
+	ks, exists := s.keyspaceOf(key)
+	return ks.typeName, exists
+}
+
+// keyspaceOf returns the first keyspace holding key
+func (s *Server) keyspaceOf(key string) (namedKeyspace, bool) {
+	for _, ks := range s.snapshotKeyspaces() {
+		if ks.keyspace.Exists(key) {
+			return ks, true
+		}
+	}
+	return namedKeyspace{}, false
+}
+
+// typeCmd implements TYPE key, replying none for missing keys
+func (s *Server) typeCmd(ctx *command.Context) error {
+	typeName, exists := s.keyType(ctx.Args[1])
+	if !exists {
+		return ctx.ReplyStatus("none")
+	}
+	return ctx.ReplyStatus(typeName)
+}
+
+// del implements DEL key [key ...]. A key is removed from every keyspace
+// holding it and counted once.
+func (s *Server) del(ctx *command.Context) error {
+	var deleted int64
+	for _, key := range ctx.Args[1:] {
+		found := false
+		for _, ks := range s.snapshotKeyspaces() {
+			if ks.keyspace.Delete(key) {
+				found = true
+			}
+		}
+		if found {
+			deleted++
+		}
+	}
+	return ctx.ReplyInt(deleted)
+}
+
+// exists implements EXISTS key [key ...]. As in redis, a key given more
+// than once is counted each time.
+func (s *Server) exists(ctx *command.Context) error {
+	var count int64
+	for _, key := range ctx.Args[1:] {
+		if _, exists := s.keyType(key); exists {
+			count++
+		}
+	}
+	return ctx.ReplyInt(count)
+}
+
+// keys implements KEYS pattern, replying with the matching keys of every
+// keyspace in sorted order
+func (s *Server) keys(ctx *command.Context) error {
+	seen := make(map[string]struct{})
+	for _, ks := range s.snapshotKeyspaces() {
+		for _, key := range ks.keyspace.Keys(ctx.Args[1]) {
+			seen[key] = struct{}{}
+		}
+	}
+	return ctx.ReplyValue(store.SortedKeys(seen))
+}
+
+// object implements OBJECT FREQ key | IDLETIME key for keyspaces that are
+// AccessTrackers. FREQ is the number of reads since the key was written and
+// IDLETIME the seconds since it was last read. Missing keys get a null reply.
+func (s *Server) object(ctx *command.Context) error {
+	sub := strings.ToUpper(ctx.Args[1])
+	if (sub != "FREQ" && sub != "IDLETIME") || len(ctx.Args) != 3 {
+		return fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try OBJECT HELP.", ctx.Args[1])
+	}
+
+	ks, exists := s.keyspaceOf(ctx.Args[2])
+	if !exists {
+		return ctx.ReplyNotFound()
+	}
+	info, tracked := accessInfo(ks.keyspace, ctx.Args[2])
+	if !tracked {
+		return fmt.Errorf("ERR access is not tracked for keys of type '%s'", ks.typeName)
+	}
+
+	if sub == "FREQ" {
+		return ctx.ReplyInt(info.Hits)
+	}
+	return ctx.ReplyInt(int64(time.Since(info.LastAccess) / time.Second))
+}
+
+// accessInfo returns the access metadata of key if ks tracks it
+func accessInfo(ks Keyspace, key string) (store.AccessInfo, bool) {
+	tracker, ok := ks.(AccessTracker)
+	if !ok {
+		return store.AccessInfo{}, false
+	}
+	return tracker.AccessInfo(key)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+	"github.com/aakash-a-dev/Goluxis/pkg/persist"
+	"github.com/aakash-a-dev/Goluxis/pkg/store"
+)
+
+// newKVServer returns a Server with KV.SET and KV.GET over a fresh store
+// registered as a keyspace, logging writes to the AOF at path
+func newKVServer(t *testing.T, path string) *Server {
+	t.Helper()
+
+	values := store.New[string]()
+	ext := command.NewExtension("kv")
+
+	set := command.New("KV.SET")
+	set.Flags = command.FlagWrite
+	set.MinArgs = 2
+	set.MaxArgs = 2
+	set.Handler = func(ctx *command.Context) error {
+		values.Set(ctx.Args[1], ctx.Args[2])
+		return ctx.ReplyStatus("OK")
+	}
+
+	get := command.New("KV.GET")
+	get.Flags = command.FlagReadOnly
+	get.MinArgs = 1
+	get.MaxArgs = 1
+	get.Handler = func(ctx *command.Context) error {
+		v, exists := values.Get(ctx.Args[1])
+		if !exists {
+			return ctx.ReplyNull()
+		}
+		return ctx.Reply(v)
+	}
+
+	if err := ext.AddCommands(set, get); err != nil {
+		t.Fatal(err)
+	}
+	srv := New(ext)
+	srv.RegisterKeyspace("string", StoreKeyspace(values))
+	srv.EnableAOF(path, persist.FsyncAlways)
+	return srv
+}
+
+// TestAOFReplaysDel checks that a DEL logged to the AOF is replayed on
+// restart, which needs the key commands registered before the replay
+func TestAOFReplaysDel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+
+	srv := newKVServer(t, path)
+	c := dial(t, startServer(t, srv))
+	for _, args := range [][]string{
+		{"KV.SET", "a", "1"},
+		{"KV.SET", "b", "2"},
+		{"DEL", "a"},
+	} {
+		if _, err := c.Do(args...); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	c.Close()
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	c = dial(t, startServer(t, newKVServer(t, path)))
+	if reply, err := c.Do("KV.GET", "a"); err != nil || !reply.IsNil() {
+		t.Errorf("KV.GET a after restart = %v, %v, want nil", reply, err)
+	}
+	if reply, err := c.Do("EXISTS", "a"); err != nil {
+		t.Errorf("EXISTS a after restart: %v", err)
+	} else if n, _ := reply.Int(); n != 0 {
+		t.Errorf("EXISTS a after restart = %d, want 0", n)
+	}
+	if reply, err := c.Do("KV.GET", "b"); err != nil {
+		t.Errorf("KV.GET b after restart: %v", err)
+	} else if s, _ := reply.Str(); s != "2" {
+		t.Errorf("KV.GET b after restart = %q, want 2", s)
+	}
+}
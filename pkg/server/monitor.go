@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// monitorBacklog is the number of feed lines queued for a monitor. A
+// monitor that falls further behind is disconnected rather than allowed to
+// slow down the clients whose commands it is watching.
+const monitorBacklog = 1024
+
+// addMonitor subscribes a connection to the command feed
+func (s *Server) addMonitor(c *conn) {
+	s.monitorMu.Lock()
+	defer s.monitorMu.Unlock()
+
+	if _, exists := s.monitors[c]; exists {
+		return
+	}
+	feed := make(chan string, monitorBacklog)
+	s.monitors[c] = feed
+	go c.writeFeed(feed)
+}
+
+// removeMonitor unsubscribes a connection from the command feed
+func (s *Server) removeMonitor(c *conn) {
+	s.monitorMu.Lock()
+	defer s.monitorMu.Unlock()
+
+	if feed, exists := s.monitors[c]; exists {
+		delete(s.monitors, c)
+		close(feed)
+	}
+}
+
+// feedMonitors sends a command executed by c to every monitor. Commands of
+// monitors themselves are not fed, so monitors cannot observe each other.
+func (s *Server) feedMonitors(c *conn, args []string) {
+	s.monitorMu.RLock()
+	defer s.monitorMu.RUnlock()
+
+	if len(s.monitors) == 0 || c.session.Monitoring() {
+		return
+	}
+
+	line := formatMonitorLine(time.Now(), c.session.DB(), c.session.Addr(), args)
+	for m, feed := range s.monitors {
+		select {
+		case feed <- line:
+		default:
+			s.Logger.Printf("Disconnecting monitor %s: feed backlog exceeded", m.session.Addr())
+			m.close()
+		}
+	}
+}
+
+// writeFeed writes feed lines to the connection until the feed is closed
+func (c *conn) writeFeed(feed <-chan string) {
+	for line := range feed {
+		c.wmu.Lock()
+		err := c.writer.WriteSimpleString(line)
+		c.wmu.Unlock()
+		if err != nil {
+			c.close()
+			return
+		}
+	}
+}
+
+// formatMonitorLine formats a command the way redis MONITOR does:
+//
+//	1339518083.107412 [0 127.0.0.1:60866] "keys" "*"
+func formatMonitorLine(t time.Time, db int, addr string, args []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d.%06d [%d %s]", t.Unix(), t.Nanosecond()/1000, db, addr)
+	for _, arg := range args {
+		b.WriteByte(' ')
+		quoteArg(&b, arg)
+	}
+	return b.String()
+}
+
+// quoteArg writes s as a double-quoted string, escaping quotes, backslashes
+// and non-printable bytes like redis's sdscatrepr
+func quoteArg(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			if c < ' ' || c > '~' {
+				fmt.Fprintf(b, `\x%02x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
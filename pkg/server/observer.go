@@ -0,0 +1,63 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// TraceAttribute is the RESP3 attribute a client sends ahead of a command
+// to tag it with its own trace id
+const TraceAttribute = "trace-id"
+
+// CommandEvent describes a single command execution
+type CommandEvent struct {
+	// TraceID correlates the command with the client's own logs. It is the
+	// id sent by the client, or a generated one if none was sent.
+	TraceID string
+	// Command is the name the command was registered under
+	Command string
+	// Args holds the command name as sent by the client and its arguments
+	Args []string
+	// ClientID is the id of the connection that sent the command
+	ClientID int64
+	// Duration is the time spent in the handler
+	Duration time.Duration
+	// Err is the error returned by the handler, if any
+	Err error
+}
+
+// Observer is notified after every command the Server executes, for
+// example to record metrics or traces. ObserveCommand is called on the
+// connection's goroutine, so it must be safe for concurrent use and
+// should return quickly.
+type Observer interface {
+	ObserveCommand(e CommandEvent)
+}
+
+// ObserverFunc adapts an ordinary function to the Observer interface
+type ObserverFunc func(e CommandEvent)
+
+// ObserveCommand calls f(e)
+func (f ObserverFunc) ObserveCommand(e CommandEvent) {
+	f(e)
+}
+
+// newTraceID generates a random 128-bit trace id in hex, the same shape as
+// a W3C trace context id
+func newTraceID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// traceID returns the trace id attached to the command just read, or a
+// newly generated one
+func (c *conn) traceID() string {
+	if attrs := c.reader.TakeAttributes(); attrs != nil {
+		if id, ok := attrs[TraceAttribute].(string); ok && id != "" {
+			return id
+		}
+	}
+	return newTraceID()
+}
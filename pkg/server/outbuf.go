@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+)
+
+// ErrOutputBufferLimit is returned by WritePush for a client that was
+// disconnected for exceeding its output buffer limit
+var ErrOutputBufferLimit = errors.New("output buffer limit exceeded")
+
+// OutputBufferLimit bounds the output queued for a client but not yet
+// written to it, like one class of redis's client-output-buffer-limit. A
+// client with more than Hard bytes queued, or with more than Soft bytes
+// queued for SoftPeriod, is disconnected. Zero disables a check.
+type OutputBufferLimit struct {
+	Hard       int64
+	Soft       int64
+	SoftPeriod time.Duration
+}
+
+// OutputBufferLimits holds a limit per client class. Clients subscribed to
+// pub/sub channels are in the PubSub class, all others in Normal.
+type OutputBufferLimits struct {
+	Normal OutputBufferLimit
+	PubSub OutputBufferLimit
+}
+
+// DefaultOutputBufferLimits matches redis: normal clients are not limited,
+// pub/sub clients are disconnected over 32MB, or over 8MB for a minute
+var DefaultOutputBufferLimits = OutputBufferLimits{
+	PubSub: OutputBufferLimit{Hard: 32 << 20, Soft: 8 << 20, SoftPeriod: time.Minute},
+}
+
+// exceeded reports whether queued bytes break the limit, given when the
+// queue went over the soft limit
+func (l OutputBufferLimit) exceeded(queued int64, softSince, now time.Time) bool {
+	if l.Hard > 0 && queued > l.Hard {
+		return true
+	}
+	return l.Soft > 0 && !softSince.IsZero() && now.Sub(softSince) >= l.SoftPeriod
+}
+
+// outputLimitExceeded checks the queued push frames against the limit of
+// the client's class. The caller must hold c.mu.
+func (c *conn) outputLimitExceeded(now time.Time) bool {
+	limit := c.srv.OutputBufferLimit.Normal
+	if c.session.Subscriptions() > 0 {
+		limit = c.srv.OutputBufferLimit.PubSub
+	}
+
+	if limit.Soft > 0 && c.queued > limit.Soft {
+		if c.softSince.IsZero() {
+			c.softSince = now
+		}
+	} else {
+		c.softSince = time.Time{}
+	}
+	return limit.exceeded(c.queued, c.softSince, now)
+}
+
+// encodePush encodes a push frame for the protocol. RESP2 clients, which
+// only get pushes while subscribed, receive it as an array.
+func encodePush(protocol int, kind string, elements []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	w := resp.NewWriter(&buf)
+	w.SetProtocol(protocol)
+
+	var err error
+	if protocol < resp.RESP3 {
+		err = w.WriteValue(append([]interface{}{kind}, elements...))
+	} else {
+		err = w.WritePush(kind, elements...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"time"
+)
+
+// deadliner is implemented by listeners whose Accept can time out, such as
+// *net.TCPListener and *net.UnixListener
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
+// Pause stops the server from accepting connections and executing
+// commands for d, like redis's CLIENT PAUSE, without closing the listener.
+// Clients that connect meanwhile wait in the listen backlog, and commands
+// that arrive wait unexecuted, until the pause ends; replies and push
+// frames already under way are still written. A pause that would end
+// earlier than the current one does not shorten it. Resume ends it early.
+//
+// Accepting stops at once for listeners with a SetDeadline method, as TCP
+// and Unix listeners have. With other listeners, one more connection may be
+// accepted, though its commands still wait.
+func (s *Server) Pause(d time.Duration) {
+	s.pauseMu.Lock()
+	if end := time.Now().Add(d); end.After(s.pauseEnd) {
+		s.pauseEnd = end
+	}
+	if s.resumed == nil {
+		s.resumed = make(chan struct{})
+	}
+	s.pauseMu.Unlock()
+
+	// Wake the accept loop so that it sees the pause
+	s.mu.Lock()
+	listener := s.listener
+	s.mu.Unlock()
+	if l, ok := listener.(deadliner); ok {
+		l.SetDeadline(time.Now())
+	}
+}
+
+// Resume ends a pause started with Pause
+func (s *Server) Resume() {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+
+	s.pauseEnd = time.Time{}
+	if s.resumed != nil {
+		close(s.resumed)
+		s.resumed = nil
+	}
+}
+
+// Paused reports whether the server is paused
+func (s *Server) Paused() bool {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+
+	return time.Now().Before(s.pauseEnd)
+}
+
+// waitPause blocks until the server is not paused. It returns false if the
+// server shuts down first.
+func (s *Server) waitPause() bool {
+	for {
+		s.pauseMu.Lock()
+		wait := time.Until(s.pauseEnd)
+		resumed := s.resumed
+		s.pauseMu.Unlock()
+		if wait <= 0 {
+			return true
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-resumed:
+		case <-s.done:
+			timer.Stop()
+			return false
+		}
+		timer.Stop()
+	}
+}
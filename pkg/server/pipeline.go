@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+	"github.com/aakash-a-dev/Goluxis/pkg/resp"
+)
+
+// pipeline runs the FlagConcurrent commands of a connection concurrently
+// and writes their replies in the order the commands were read. Replies
+// that finish early wait in a reorder buffer keyed by sequence number.
+type pipeline struct {
+	slots   chan struct{} // held from a command's start until its reply is written
+	running sync.WaitGroup
+	issued  uint64 // sequence number of the next command, read loop only
+
+	mu      sync.Mutex
+	next    uint64 // sequence number of the next reply to write
+	pending map[uint64]pipelineReply
+	failed  bool // a write failed or a reply was incomplete
+}
+
+// pipelineReply is the buffered reply of a command run by the pipeline
+type pipelineReply struct {
+	data     []byte
+	keepOpen bool
+}
+
+// newPipeline creates a pipeline running at most n commands at once.
+// Replies waiting for an earlier one count towards n, so at most n are
+// buffered.
+func newPipeline(n int) *pipeline {
+	return &pipeline{
+		slots:   make(chan struct{}, n),
+		pending: make(map[uint64]pipelineReply),
+	}
+}
+
+// bufferedConn is the command.RedisConn of a command run by the pipeline.
+// Replies go to a buffer; push frames go to the connection as usual.
+type bufferedConn struct {
+	replier
+	buf bytes.Buffer
+}
+
+// WritePush queues a push frame on the connection
+func (b *bufferedConn) WritePush(kind string, elements ...interface{}) error {
+	return b.c.WritePush(kind, elements...)
+}
+
+// RawReader returns the connection's reader. FlagConcurrent handlers must
+// not read from it, since the read loop is reading the next command.
+func (b *bufferedConn) RawReader() *resp.Reader {
+	return b.c.reader
+}
+
+// start runs cmd in the background, waiting first if n commands are in
+// flight. It is called by the read loop.
+func (p *pipeline) start(c *conn, cmd *command.Command, args []string, traceID string) {
+	p.slots <- struct{}{}
+	seq := p.issued
+	p.issued++
+
+	p.running.Add(1)
+	go func() {
+		defer p.running.Done()
+
+		b := &bufferedConn{}
+		b.replier = replier{c: c, writer: resp.NewWriter(&b.buf)}
+		keepOpen := c.run(&b.replier, b, cmd, args, traceID)
+		p.finish(c, seq, pipelineReply{data: b.buf.Bytes(), keepOpen: keepOpen})
+	}()
+}
+
+// finish buffers the reply of command seq and writes every reply that is
+// no longer waiting for an earlier one. After a failure the connection is
+// closed and later replies are dropped.
+func (p *pipeline) finish(c *conn, seq uint64, reply pipelineReply) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending[seq] = reply
+	for {
+		reply, ok := p.pending[p.next]
+		if !ok {
+			return
+		}
+		delete(p.pending, p.next)
+		p.next++
+		<-p.slots
+
+		if p.failed {
+			continue
+		}
+		c.wmu.Lock()
+		_, err := c.writer.Write(reply.data)
+		if err == nil {
+			err = c.writer.Flush()
+		}
+		c.wmu.Unlock()
+		if err != nil || !reply.keepOpen {
+			p.failed = true
+			c.close()
+		}
+	}
+}
+
+// wait blocks until every command started has finished and its reply has
+// been written. It does nothing on a nil pipeline.
+func (p *pipeline) wait() {
+	if p == nil {
+		return
+	}
+	p.running.Wait()
+}
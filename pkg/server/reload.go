@@ -0,0 +1,107 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+)
+
+// OnReload registers a function that builds a new version of an
+// extension, run by Reload and on SIGHUP. The extension it returns
+// replaces the registered extension of the same name, or is added if there
+// is none. Commands already executing finish with the old version and
+// later ones use the new one; connections are kept. If fn fails, or the new
+// version has a command another extension serves, the old version stays.
+// Start and stop hooks of the new version are not run.
+//
+// SIGHUP is only watched if OnReload is called before serving starts.
+func (s *Server) OnReload(fn func() (*command.Extension, error)) {
+	s.hookMu.Lock()
+	defer s.hookMu.Unlock()
+
+	s.reloadHooks = append(s.reloadHooks, fn)
+}
+
+// Reload runs the functions registered with OnReload and swaps in the
+// extensions they return. Failures are logged and returned together; the
+// extensions that were built successfully are swapped in regardless.
+func (s *Server) Reload() error {
+	s.hookMu.RLock()
+	hooks := s.reloadHooks
+	s.hookMu.RUnlock()
+
+	var errs []error
+	for _, fn := range hooks {
+		ext, err := fn()
+		if err == nil && ext == nil {
+			err = errors.New("reload returned no extension")
+		}
+		if err == nil {
+			err = s.replaceExtension(ext)
+		}
+		if err != nil {
+			s.Logger.Printf("Reload failed, keeping the current commands: %v", err)
+			errs = append(errs, err)
+			continue
+		}
+		s.Logger.Printf("Reloaded extension %s", ext.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// replaceExtension swaps ext in for the registered extension of the same
+// name, or registers it
+func (s *Server) replaceExtension(ext *command.Extension) error {
+	s.extMu.Lock()
+	defer s.extMu.Unlock()
+
+	for i, old := range s.exts {
+		if old.Name != ext.Name {
+			continue
+		}
+		if err := s.checkConflicts(ext, old); err != nil {
+			return fmt.Errorf("reloading extension %s: %w", ext.Name, err)
+		}
+		// Copied so that slices of the old list are left unchanged
+		exts := slices.Clone(s.exts)
+		exts[i] = ext
+		s.exts = exts
+		return nil
+	}
+
+	if err := s.checkConflicts(ext, nil); err != nil {
+		return fmt.Errorf("reloading extension %s: %w", ext.Name, err)
+	}
+	s.exts = append(s.exts, ext)
+	return nil
+}
+
+// watchReload calls Reload on SIGHUP until the server shuts down, if any
+// reload functions are registered
+func (s *Server) watchReload() {
+	s.hookMu.RLock()
+	registered := len(s.reloadHooks) > 0
+	s.hookMu.RUnlock()
+	if !registered {
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				s.Reload()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,776 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+	"github.com/aakash-a-dev/Goluxis/pkg/persist"
+	"github.com/aakash-a-dev/Goluxis/pkg/store"
+)
+
+var (
+	// ErrServerClosed is returned by Serve and ListenAndServe after Shutdown
+	ErrServerClosed = errors.New("server closed")
+	// ErrInternal is replied when a command handler panics
+	ErrInternal = errors.New("ERR internal error")
+	// ErrTooManyRequests is replied to commands over PerConnCommandLimit
+	ErrTooManyRequests = errors.New("ERR too many requests")
+	// ErrCommandTimeout is replied when a handler gives up at its
+	// CommandTimeout deadline
+	ErrCommandTimeout = errors.New("ERR command timed out")
+	// ErrReplyTooLarge is replied when a reply exceeds MaxReplyBytes before
+	// any of it was sent
+	ErrReplyTooLarge = errors.New("ERR reply too large")
+)
+
+// DefaultCompressionThreshold is the smallest reply that is compressed
+// for clients that negotiated compression
+const DefaultCompressionThreshold = 1024
+
+// DefaultDatabases is the default number of logical databases clients can
+// SELECT, matching redis
+const DefaultDatabases = 16
+
+// Logger is the logging interface used by the Server
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Server serves the commands of one or more Extensions over the RESP
+// protocol. Its options must be set before serving starts; those listed by
+// CONFIG GET can then be changed with CONFIG SET.
+type Server struct {
+	// Logger receives connection and dispatch errors. Defaults to the
+	// standard library logger.
+	Logger Logger
+
+	// CompressionThreshold is the smallest payload, in bytes, that
+	// Context.ReplyCompressed compresses. Smaller payloads are sent as is.
+	CompressionThreshold int
+
+	// MaxLineLength caps the length of a single protocol line read from a
+	// client. Zero uses resp.DefaultMaxLineLength.
+	MaxLineLength int
+
+	// MaxBulkLength caps the length of a bulk string read from a client.
+	// Zero uses resp.DefaultMaxBulkLength.
+	MaxBulkLength int64
+
+	// MaxAggregateLength caps the number of arguments of a command, and of
+	// elements of any other aggregate, read from a client. Zero uses
+	// resp.DefaultMaxAggregateLength.
+	MaxAggregateLength int64
+
+	// AnnounceDeprecations attaches a "deprecated" RESP3 attribute holding
+	// Command.Deprecated to the replies of deprecated commands. Deprecated
+	// commands are logged once per connection either way.
+	AnnounceDeprecations bool
+
+	// UnknownCommand, if set, builds the error replied for a command that is
+	// not served, given the command name and its arguments. It defaults to
+	// UnknownCommandError, which matches redis's reply.
+	UnknownCommand func(name string, args []string) error
+
+	// Observer, if set, is notified after every command executed for a
+	// client
+	Observer Observer
+
+	// MaxClients caps the number of concurrent client connections. Clients
+	// connecting beyond it are sent an error and disconnected. Zero means
+	// no limit.
+	MaxClients int
+
+	// IdleTimeout, if positive, closes connections that send no command for
+	// that long. Clients subscribed to pub/sub channels or running MONITOR
+	// are not timed out.
+	IdleTimeout time.Duration
+
+	// Databases is the number of logical databases clients can SELECT.
+	// Extensions read the selected index with Context.DB.
+	Databases int
+
+	// PerConnCommandLimit, if positive, limits each connection to that many
+	// commands per second, allowing bursts of the same size. Commands over
+	// the limit are not executed and get ErrTooManyRequests.
+	PerConnCommandLimit int
+
+	// MaxLimitViolations, if positive, closes a connection after that many
+	// consecutive commands rejected by PerConnCommandLimit
+	MaxLimitViolations int
+
+	// CommandTimeout, if positive, is the deadline of the context returned
+	// by Context.Context. Handlers doing long work should stop once it is
+	// done; a handler that returns the context's error without replying
+	// gets ErrCommandTimeout. Handlers are not interrupted otherwise.
+	CommandTimeout time.Duration
+
+	// MaxReplyBytes, if positive, caps the size of a single command's
+	// reply. A reply that outgrows it is abandoned: the client gets
+	// ErrReplyTooLarge if nothing was sent yet, and is disconnected if the
+	// reply was already partly sent.
+	MaxReplyBytes int
+
+	// ConcurrentCommands is how many commands flagged FlagConcurrent a
+	// single connection may execute at once when the client pipelines
+	// them. Their replies are buffered and written in the order the
+	// commands arrived. Other commands wait for those in flight to finish
+	// and run alone. Zero or one executes every command in turn.
+	ConcurrentCommands int
+
+	// OutputBufferLimit bounds the push frames, such as pub/sub messages,
+	// queued for clients that read them slower than they are sent.
+	// Defaults to DefaultOutputBufferLimits.
+	OutputBufferLimit OutputBufferLimits
+
+	// SlowLogThreshold, if positive, is the execution time from which a
+	// command is recorded in the slow log read with SLOWLOG GET
+	SlowLogThreshold time.Duration
+
+	// SlowLogMaxLen is the number of slow log entries kept; older entries
+	// are dropped. Defaults to DefaultSlowLogMaxLen.
+	SlowLogMaxLen int
+
+	// EnableDebugCommands serves DEBUG SLEEP, which blocks a connection for
+	// a given time to exercise timeouts and concurrency. It is read when
+	// serving starts and must not be set in production.
+	EnableDebugCommands bool
+
+	// CountNetworkBytes counts the bytes read from and written to clients,
+	// reported by INFO as total_net_input_bytes and total_net_output_bytes.
+	// It applies to connections accepted after it is set.
+	CountNetworkBytes bool
+
+	// DumpSignal, if set, makes the server run DumpState when the process
+	// receives it, such as syscall.SIGUSR1. It is read when serving starts.
+	DumpSignal os.Signal
+
+	exts     []*command.Extension
+	builtins *command.Extension
+	extMu    sync.RWMutex
+	lastID   atomic.Int64
+
+	snapshotPath     string
+	snapshotter      persist.Snapshotter
+	snapshotInterval time.Duration
+
+	aofPath   string
+	aofPolicy persist.FsyncPolicy
+	aof       *persist.AOF
+	aofMu     sync.RWMutex
+	aofKeys   *store.KeyedMutex // orders the appends of writes to a key
+
+	resetHooks  []func(ctx *command.Context)
+	reloadHooks []func() (*command.Extension, error)
+	middleware  []command.Middleware
+	hookMu      sync.RWMutex // guards resetHooks, reloadHooks and middleware
+
+	configMu sync.RWMutex // guards options changed by CONFIG SET
+
+	keyspaces  []namedKeyspace
+	keyspaceMu sync.RWMutex
+
+	monitors  map[*conn]chan string
+	monitorMu sync.RWMutex
+
+	ready     atomic.Bool
+	health    *http.Server
+	lastErr   error
+	lastErrAt time.Time
+	errMu     sync.Mutex
+
+	started           time.Time
+	commandsProcessed atomic.Int64
+	rejectedConns     atomic.Int64
+	netInputBytes     atomic.Int64 // of closed connections
+	netOutputBytes    atomic.Int64 // of closed connections
+	cmdStats          map[string]*commandStat
+	statsMu           sync.Mutex
+
+	slowLog slowLog
+	slowMu  sync.Mutex
+
+	running     []*command.Extension // extensions whose start hooks ran
+	stopRunning context.CancelFunc   // cancels the start hooks' context
+
+	pauseEnd time.Time     // accepting and executing wait until then
+	resumed  chan struct{} // closed by Resume
+	pauseMu  sync.Mutex
+
+	listener net.Listener
+	conns    map[*conn]struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+}
+
+// New creates a new Server serving the given extension. More extensions
+// can be added with Register; ext may be nil if all are added that way.
+func New(ext *command.Extension) *Server {
+	s := &Server{
+		Logger:               log.Default(),
+		CompressionThreshold: DefaultCompressionThreshold,
+		Databases:            DefaultDatabases,
+		SlowLogMaxLen:        DefaultSlowLogMaxLen,
+		OutputBufferLimit:    DefaultOutputBufferLimits,
+		conns:                make(map[*conn]struct{}),
+		monitors:             make(map[*conn]chan string),
+		cmdStats:             make(map[string]*commandStat),
+		started:              time.Now(),
+		done:                 make(chan struct{}),
+	}
+	s.builtins = s.newBuiltins()
+	if ext != nil {
+		s.exts = append(s.exts, ext)
+	}
+	return s
+}
+
+// Register adds an extension to the Server. It fails if any of the
+// extension's commands has the same name as a command already served,
+// since the second one could never be reached. Commands added to an
+// extension after it is registered are not checked.
+func (s *Server) Register(ext *command.Extension) error {
+	if ext == nil {
+		return errors.New("extension cannot be nil")
+	}
+
+	s.extMu.Lock()
+	defer s.extMu.Unlock()
+
+	if err := s.checkConflicts(ext, nil); err != nil {
+		return err
+	}
+	s.exts = append(s.exts, ext)
+	return nil
+}
+
+// checkConflicts fails if a command of ext is already served by the
+// builtins or another extension than replaced. The caller must hold
+// s.extMu.
+func (s *Server) checkConflicts(ext, replaced *command.Extension) error {
+	owners := make(map[string]string)
+	for _, e := range append([]*command.Extension{s.builtins}, s.exts...) {
+		if e == replaced {
+			continue
+		}
+		for _, name := range e.CommandNames() {
+			owners[strings.ToUpper(name)] = e.Name
+		}
+	}
+	for _, name := range ext.CommandNames() {
+		if owner, exists := owners[strings.ToUpper(name)]; exists {
+			return fmt.Errorf("command %s of extension %s is already registered by extension %s", name, ext.Name, owner)
+		}
+	}
+	return nil
+}
+
+// CommandInfo describes a command served by the Server
+type CommandInfo struct {
+	// Name is the name clients use to invoke the command
+	Name string
+	// Extension is the name of the extension that owns the command
+	Extension string
+	// Command is the registered command
+	Command *command.Command
+}
+
+// Commands returns every command served, including built-ins, sorted by name
+func (s *Server) Commands() []CommandInfo {
+	s.extMu.RLock()
+	defer s.extMu.RUnlock()
+
+	var infos []CommandInfo
+	for _, ext := range append([]*command.Extension{s.builtins}, s.exts...) {
+		for _, name := range ext.CommandNames() {
+			cmd, err := ext.GetCommand(name)
+			if err != nil {
+				continue
+			}
+			infos = append(infos, CommandInfo{Name: name, Extension: ext.Name, Command: cmd})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// EnableSnapshots makes the Server persist state through snap. The snapshot
+// at path is loaded before the Server starts accepting connections, saved
+// every interval while serving (if interval is positive), and saved once
+// more on Shutdown.
+func (s *Server) EnableSnapshots(path string, snap persist.Snapshotter, interval time.Duration) {
+	s.snapshotPath = path
+	s.snapshotter = snap
+	s.snapshotInterval = interval
+}
+
+// EnableAOF makes the Server append every command flagged command.FlagWrite
+// to the log at path. The log is replayed through the command handlers
+// before the Server starts accepting connections. Commands are logged with
+// ctx.Args as the handler left them, so a handler resolving an argument
+// that depends on when it runs, such as the current time, should replace
+// it with the resolved value.
+func (s *Server) EnableAOF(path string, policy persist.FsyncPolicy) {
+	s.aofPath = path
+	s.aofPolicy = policy
+}
+
+// RewriteAOF compacts the AOF by replacing it with the commands emitted by
+// rw. Write commands are held back until the rewrite completes so that none
+// are lost or duplicated.
+func (s *Server) RewriteAOF(rw persist.Rewriter) error {
+	s.aofMu.Lock()
+	defer s.aofMu.Unlock()
+
+	if s.aof == nil {
+		return errors.New("AOF is not enabled")
+	}
+	return s.aof.Rewrite(rw)
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener until Shutdown is called.
+// It always returns a non-nil error; after Shutdown it returns ErrServerClosed.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	// Built-in commands are added first, since the AOF replay below runs
+	// the commands it logged
+	s.addDebugCommands()
+	s.addKeyCommands()
+
+	if s.snapshotter != nil {
+		if err := persist.LoadFile(s.snapshotPath, s.snapshotter); err != nil {
+			listener.Close()
+			return err
+		}
+		if s.snapshotInterval > 0 {
+			go s.snapshotLoop()
+		}
+	}
+
+	if s.aofPath != "" {
+		if err := s.openAOF(); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	if err := s.startExtensions(); err != nil {
+		listener.Close()
+		return err
+	}
+
+	s.watchReload()
+	s.watchDump()
+
+	s.ready.Store(true)
+	deadlines, _ := listener.(deadliner)
+	for {
+		// Cleared before checking for a pause so that a Pause from now on
+		// interrupts the Accept below
+		if deadlines != nil {
+			deadlines.SetDeadline(time.Time{})
+		}
+		if !s.waitPause() {
+			return ErrServerClosed
+		}
+
+		netConn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				// Interrupted by Pause
+				continue
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				s.Logger.Printf("Failed to accept connection: %v", err)
+				s.recordError(err)
+				continue
+			}
+			return err
+		}
+
+		c, err := s.admit(netConn, netConn.RemoteAddr().String())
+		if err != nil {
+			return err
+		}
+		if c != nil {
+			go c.serve()
+		}
+	}
+}
+
+// ServeConn serves a single client connection that did not come from the
+// server's listener, such as a WebSocket or an in-memory pipe, until the
+// client disconnects. addr identifies the client in CLIENT LIST and logs.
+// IdleTimeout only applies if rwc has a SetReadDeadline method. The server
+// must already be serving, since extensions are started by Serve.
+func (s *Server) ServeConn(rwc io.ReadWriteCloser, addr string) error {
+	c, err := s.admit(rwc, addr)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return errors.New("max number of clients reached")
+	}
+	c.serve()
+	return nil
+}
+
+// admit sets up a new connection for serving. If the server is at
+// MaxClients the client is told so and disconnected in the background, and
+// a nil conn is returned.
+func (s *Server) admit(rwc io.ReadWriteCloser, addr string) (*conn, error) {
+	if s.atCapacity() {
+		s.rejectedConns.Add(1)
+		go reject(rwc)
+		return nil, nil
+	}
+
+	c := newConn(s, rwc, addr)
+	if !s.trackConn(c) {
+		rwc.Close()
+		return nil, ErrServerClosed
+	}
+	return c, nil
+}
+
+// rejectTimeout bounds how long a client turned away at MaxClients is
+// given to receive the error
+const rejectTimeout = time.Second
+
+// reject tells a client over MaxClients so and closes its connection. It
+// runs on its own goroutine so that a client slow to read does not hold up
+// accepting others.
+func reject(rwc io.ReadWriteCloser) {
+	if d, ok := rwc.(writeDeadliner); ok {
+		d.SetWriteDeadline(time.Now().Add(rejectTimeout))
+	}
+	rwc.Write([]byte("-ERR max number of clients reached\r\n"))
+	rwc.Close()
+}
+
+// Shutdown stops accepting new connections and waits for active ones to
+// finish until ctx is done, after which remaining connections are closed.
+// A final snapshot is saved if snapshots are enabled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		return nil
+	}
+	close(s.done)
+	s.ready.Store(false)
+	health := s.health
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	// Idle connections are blocked reading the next command; busy ones
+	// exit after their current command completes
+	for c := range s.conns {
+		c.closeIfIdle()
+	}
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		s.closeConns()
+		<-finished
+	}
+
+	if s.snapshotter != nil {
+		if saveErr := s.saveSnapshot(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	if s.aof != nil {
+		if closeErr := s.aof.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	if stopErr := s.stopExtensions(); stopErr != nil && err == nil {
+		err = stopErr
+	}
+	if health != nil {
+		health.Close()
+	}
+	return err
+}
+
+// startExtensions runs the start hooks of every registered extension. If
+// one fails, the extensions already started are stopped again.
+func (s *Server) startExtensions() error {
+	s.extMu.RLock()
+	exts := append([]*command.Extension(nil), s.exts...)
+	s.extMu.RUnlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.stopRunning = cancel
+	s.mu.Unlock()
+
+	for _, ext := range exts {
+		if err := ext.Start(ctx); err != nil {
+			s.stopExtensions()
+			return err
+		}
+		s.mu.Lock()
+		s.running = append(s.running, ext)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// stopExtensions cancels the start hooks' context and runs the stop hooks
+// of the started extensions in reverse order, returning the first error
+func (s *Server) stopExtensions() error {
+	s.mu.Lock()
+	running := s.running
+	s.running = nil
+	if s.stopRunning != nil {
+		s.stopRunning()
+	}
+	s.mu.Unlock()
+
+	var err error
+	for i := len(running) - 1; i >= 0; i-- {
+		if stopErr := running[i].Stop(); stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}
+	return err
+}
+
+// openAOF opens the AOF and replays it to rebuild state
+func (s *Server) openAOF() error {
+	aof, err := persist.OpenAOF(s.aofPath, s.aofPolicy)
+	if err != nil {
+		return err
+	}
+
+	// Replayed commands share one session so that logged SELECTs apply
+	// to the commands that follow them
+	session := command.NewSession(0, "aof")
+	err = aof.Replay(func(args []string) error {
+		cmd, err := s.lookup(args[0], args[1:])
+		if err != nil {
+			return err
+		}
+		ctx := &command.Context{
+			Args:    args,
+			Conn:    discardConn{},
+			Session: session,
+		}
+		if err := ctx.BindArgs(cmd.ArgSpec); err != nil {
+			return err
+		}
+		return cmd.Run(ctx)
+	})
+	if err != nil {
+		aof.Close()
+		return err
+	}
+
+	s.aof = aof
+	s.aofKeys = store.NewKeyedMutex(store.DefaultShards)
+	return nil
+}
+
+// lookup finds the command serving a request, resolved by each
+// extension's Router and by name, ignoring case, by default. Built-in
+// commands take precedence over extension commands. Requests no command
+// matches go to the first extension with a default handler that accepts
+// them.
+func (s *Server) lookup(name string, args []string) (*command.Command, error) {
+	if cmd, err := s.builtins.Resolve(name, args); err == nil {
+		return cmd, nil
+	}
+
+	s.extMu.RLock()
+	defer s.extMu.RUnlock()
+
+	for _, ext := range s.exts {
+		if cmd, err := ext.Resolve(name, args); err == nil {
+			return cmd, nil
+		}
+	}
+	for _, ext := range s.exts {
+		if cmd, err := ext.DefaultCommand(name); err == nil {
+			return cmd, nil
+		}
+	}
+	return nil, command.ErrCommandNotFound
+}
+
+// nextConnID returns a new unique connection id
+func (s *Server) nextConnID() int64 {
+	return s.lastID.Add(1)
+}
+
+// Use adds middleware that wraps every command executed for a client,
+// including the built-in commands. Middleware added first runs first.
+// Commands replayed from the AOF bypass middleware.
+func (s *Server) Use(mw ...command.Middleware) {
+	s.hookMu.Lock()
+	defer s.hookMu.Unlock()
+
+	s.middleware = append(s.middleware, mw...)
+}
+
+// execute runs a command handler wrapped in the server's middleware,
+// appending the command to the AOF if it modifies state and succeeds
+func (s *Server) execute(cmd *command.Command, ctx *command.Context) error {
+	s.hookMu.RLock()
+	run := command.Chain(cmd.Run, s.middleware...)
+	s.hookMu.RUnlock()
+
+	if s.aof == nil || !cmd.HasFlag(command.FlagWrite) {
+		return run(ctx)
+	}
+
+	// A write and its append happen under one lock so that the log holds
+	// writes to a key in the order they were executed. Writes to a single
+	// key only exclude writes to that key; the others exclude every write.
+	if keys := cmd.KeyArgs(ctx.Args); len(keys) == 1 {
+		s.aofMu.RLock()
+		defer s.aofMu.RUnlock()
+		s.aofKeys.Lock(keys[0])
+		defer s.aofKeys.Unlock(keys[0])
+	} else {
+		s.aofMu.Lock()
+		defer s.aofMu.Unlock()
+	}
+
+	if err := run(ctx); err != nil {
+		return err
+	}
+	if err := s.aof.AppendDB(ctx.DB(), ctx.Args); err != nil {
+		s.Logger.Printf("[trace %s] Failed to append to AOF: %v", ctx.TraceID, err)
+		s.recordError(err)
+	}
+	return nil
+}
+
+// isClosed reports whether Shutdown has been called
+func (s *Server) isClosed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// trackConn registers an active connection. It returns false if the
+// server is shutting down.
+func (s *Server) trackConn(c *conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isClosed() {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// untrackConn removes a connection once it has been closed
+func (s *Server) untrackConn(c *conn) {
+	s.removeMonitor(c)
+	c.session.Close()
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.netInputBytes.Add(c.reader.BytesRead())
+	s.netOutputBytes.Add(c.writer.BytesWritten())
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// connCount returns the number of active connections
+func (s *Server) connCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+// atCapacity reports whether MaxClients connections are active
+func (s *Server) atCapacity() bool {
+	max := liveConfig(s, &s.MaxClients)
+	return max > 0 && s.connCount() >= max
+}
+
+// closeConns force-closes every active connection
+func (s *Server) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		c.close()
+	}
+}
+
+// snapshotLoop saves a snapshot every interval until shutdown
+func (s *Server) snapshotLoop() {
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.saveSnapshot(); err != nil {
+				s.Logger.Printf("Failed to save snapshot: %v", err)
+				s.recordError(err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// saveSnapshot writes the current state to the snapshot file
+func (s *Server) saveSnapshot() error {
+	return persist.SaveFile(s.snapshotPath, s.snapshotter)
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"testing"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/client"
+)
+
+// startServer serves srv on a local port until the test ends and returns
+// the address to dial
+func startServer(t *testing.T, srv *Server) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Logger = log.New(io.Discard, "", 0)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(listener) }()
+	t.Cleanup(func() {
+		srv.Shutdown(context.Background())
+		if err := <-served; err != ErrServerClosed {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	})
+	return listener.Addr().String()
+}
+
+// dial connects to addr, closing the connection when the test ends
+func dial(t *testing.T, addr string) *client.Conn {
+	t.Helper()
+
+	c, err := client.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aakash-a-dev/Goluxis/pkg/command"
+)
+
+// DefaultSlowLogMaxLen is the default number of entries kept by the slow
+// log, matching redis's slowlog-max-len
+const DefaultSlowLogMaxLen = 128
+
+// Limits on the arguments kept per slow log entry, matching redis
+const (
+	slowLogMaxArgs   = 32
+	slowLogMaxString = 128
+)
+
+// slowEntry is one command recorded by the slow log
+type slowEntry struct {
+	id       int64
+	at       time.Time
+	duration time.Duration
+	args     []string
+	addr     string
+	name     string
+}
+
+// slowLog is a ring buffer of the most recent slow commands
+type slowLog struct {
+	entries []slowEntry
+	next    int // index the next entry is written to
+	count   int
+	lastID  int64
+}
+
+// add records an entry, overwriting the oldest once max entries are held
+func (l *slowLog) add(e slowEntry, max int) {
+	if max <= 0 {
+		return
+	}
+	if len(l.entries) != max {
+		l.resize(max)
+	}
+
+	e.id = l.lastID
+	l.lastID++
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % max
+	l.count = min(l.count+1, max)
+}
+
+// resize changes the capacity to max, keeping the newest entries
+func (l *slowLog) resize(max int) {
+	kept := l.newest(max)
+	l.entries = make([]slowEntry, max)
+	for i := range kept {
+		l.entries[len(kept)-1-i] = kept[i]
+	}
+	l.next = len(kept) % max
+	l.count = len(kept)
+}
+
+// newest returns up to n entries, newest first
+func (l *slowLog) newest(n int) []slowEntry {
+	n = min(n, l.count)
+	entries := make([]slowEntry, n)
+	for i := 0; i < n; i++ {
+		idx := (l.next - 1 - i + len(l.entries)) % len(l.entries)
+		entries[i] = l.entries[idx]
+	}
+	return entries
+}
+
+// reset removes every entry. Entry ids keep increasing, as in redis.
+func (l *slowLog) reset() {
+	clear(l.entries)
+	l.next = 0
+	l.count = 0
+}
+
+// recordSlow adds a command to the slow log if it ran for at least
+// SlowLogThreshold
+func (s *Server) recordSlow(c *conn, args []string, d time.Duration) {
+	if threshold := liveConfig(s, &s.SlowLogThreshold); threshold <= 0 || d < threshold {
+		return
+	}
+
+	e := slowEntry{
+		at:       time.Now(),
+		duration: d,
+		args:     slowLogArgs(args),
+		addr:     c.session.Addr(),
+		name:     c.session.Name(),
+	}
+
+	s.slowMu.Lock()
+	defer s.slowMu.Unlock()
+	s.slowLog.add(e, liveConfig(s, &s.SlowLogMaxLen))
+}
+
+// slowLogArgs copies args for a slow log entry, shortening long arguments
+// and long argument lists the way redis does
+func slowLogArgs(args []string) []string {
+	n := min(len(args), slowLogMaxArgs)
+	kept := make([]string, n)
+	for i := 0; i < n; i++ {
+		if i == slowLogMaxArgs-1 && len(args) > slowLogMaxArgs {
+			kept[i] = fmt.Sprintf("... (%d more arguments)", len(args)-slowLogMaxArgs+1)
+			break
+		}
+		arg := args[i]
+		if len(arg) > slowLogMaxString {
+			arg = fmt.Sprintf("%s... (%d more bytes)", arg[:slowLogMaxString], len(arg)-slowLogMaxString)
+		}
+		kept[i] = arg
+	}
+	return kept
+}
+
+// slowlog implements SLOWLOG GET [count] | LEN | RESET
+func (s *Server) slowlog(ctx *command.Context) error {
+	sub := strings.ToUpper(ctx.Args[1])
+	switch {
+	case sub == "GET" && len(ctx.Args) <= 3:
+		count := 10
+		if len(ctx.Args) == 3 {
+			n, err := strconv.Atoi(ctx.Args[2])
+			if err != nil || n < -1 {
+				return errors.New("ERR count should be greater than or equal to -1")
+			}
+			count = n
+		}
+
+		s.slowMu.Lock()
+		if count == -1 {
+			count = s.slowLog.count
+		}
+		entries := s.slowLog.newest(count)
+		s.slowMu.Unlock()
+
+		if err := ctx.ReplyArray(len(entries)); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			entry := []interface{}{e.id, e.at.Unix(), e.duration.Microseconds(), e.args, e.addr, e.name}
+			if err := ctx.ReplyValue(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case sub == "LEN" && len(ctx.Args) == 2:
+		s.slowMu.Lock()
+		defer s.slowMu.Unlock()
+		return ctx.ReplyInt(int64(s.slowLog.count))
+
+	case sub == "RESET" && len(ctx.Args) == 2:
+		s.slowMu.Lock()
+		s.slowLog.reset()
+		s.slowMu.Unlock()
+		return ctx.ReplyStatus("OK")
+
+	default:
+		return fmt.Errorf("ERR unknown subcommand or wrong number of arguments for '%s'. Try SLOWLOG HELP.", ctx.Args[1])
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxArgPreview is the length at which the argument preview of an unknown
+// command error is cut off, as in redis
+const maxArgPreview = 128
+
+// UnknownCommandError returns the error redis replies for an unknown
+// command, including the command name and a preview of its arguments:
+//
+//	ERR unknown command 'FOO', with args beginning with: 'a' 'b'
+func UnknownCommandError(name string, args []string) error {
+	var preview strings.Builder
+	for _, arg := range args {
+		if preview.Len() >= maxArgPreview {
+			break
+		}
+		if room := maxArgPreview - preview.Len(); len(arg) > room {
+			arg = arg[:room]
+		}
+		fmt.Fprintf(&preview, "'%s' ", arg)
+	}
+	if len(name) > maxArgPreview {
+		name = name[:maxArgPreview]
+	}
+	return fmt.Errorf("ERR unknown command '%s', with args beginning with: %s", name, preview.String())
+}
+
+// unknownCommand returns the error replied for an unknown command
+func (s *Server) unknownCommand(name string, args []string) error {
+	if s.UnknownCommand != nil {
+		return s.UnknownCommand(name, args)
+	}
+	return UnknownCommandError(name, args)
+}
+
+// SuggestCommand returns the served command whose name is closest to name,
+// ignoring case, or "" if none is close enough to be a likely typo. It can
+// be used by an UnknownCommand hook to add a did-you-mean hint.
+func (s *Server) SuggestCommand(name string) string {
+	name = strings.ToLower(name)
+	// Allow roughly one edit per three characters, and at most two
+	limit := min(2, len(name)/3)
+
+	best, bestDist := "", limit+1
+	for _, info := range s.Commands() {
+		if d := editDistance(name, strings.ToLower(info.Name)); d < bestDist {
+			best, bestDist = info.Name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
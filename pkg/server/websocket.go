@@ -0,0 +1,311 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is appended to the client's key for the handshake,
+// see RFC 6455 section 1.3
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// WebSocket close status codes
+const (
+	wsCloseNormal   = 1000
+	wsCloseProtocol = 1002
+)
+
+// errWebSocketProtocol is returned for frames breaking RFC 6455
+var errWebSocketProtocol = errors.New("websocket protocol error")
+
+// WebSocketHandler serves RESP over WebSocket connections, for clients
+// such as browsers that cannot open TCP connections. The payloads of the
+// client's binary or text messages are read as one RESP stream, so a
+// message may hold several commands or part of one. Replies are sent as
+// binary messages; like the messages read, their boundaries carry no
+// meaning and a reply may span several.
+//
+//	http.Handle("/resp", srv.WebSocketHandler())
+//	go http.ListenAndServe(":8081", nil)
+type WebSocketHandler struct {
+	srv *Server
+
+	// CheckOrigin decides whether to accept a browser connection from the
+	// page at the request's Origin header. By default only pages served
+	// from the same host are accepted, so that other sites cannot issue
+	// commands from their visitors' browsers.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// WebSocketHandler returns an http.Handler upgrading requests to
+// WebSocket connections served like those accepted by Serve
+func (s *Server) WebSocketHandler() *WebSocketHandler {
+	return &WebSocketHandler{srv: s}
+}
+
+// ServeHTTP performs the WebSocket handshake and serves the connection
+// until the client disconnects
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet ||
+		!headerHasToken(r.Header, "Connection", "upgrade") ||
+		!headerHasToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	checkOrigin := h.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		h.srv.Logger.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	// Clear any deadlines set by the HTTP server
+	netConn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return
+	}
+
+	ws := &wsConn{netConn: netConn, r: rw.Reader}
+	if err := h.srv.ServeConn(ws, netConn.RemoteAddr().String()); err != nil && err != ErrServerClosed {
+		h.srv.Logger.Printf("WebSocket connection from %s refused: %v", netConn.RemoteAddr(), err)
+	}
+}
+
+// headerHasToken reports whether the comma-separated header name contains
+// token, ignoring case
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin accepts requests without an Origin header, which browsers
+// always send, and those whose Origin has the request's host
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// wsConn is a WebSocket connection read and written as a byte stream of
+// message payloads
+type wsConn struct {
+	netConn net.Conn
+	r       *bufio.Reader
+
+	// Current data frame being read
+	remaining int64
+	mask      [4]byte
+	maskPos   int
+
+	wmu       sync.Mutex // serializes frames, including pongs sent by Read
+	closeSent bool
+}
+
+// Read reads message payloads, answering pings as it goes. It returns
+// io.EOF once the client closes the connection.
+func (ws *wsConn) Read(p []byte) (int, error) {
+	for ws.remaining == 0 {
+		if err := ws.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > ws.remaining {
+		p = p[:ws.remaining]
+	}
+	n, err := ws.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= ws.mask[ws.maskPos%4]
+		ws.maskPos++
+	}
+	ws.remaining -= int64(n)
+	return n, err
+}
+
+// nextFrame reads the next frame header, handling control frames
+// entirely. For a data frame, its payload is left to be read by Read.
+func (ws *wsConn) nextFrame() error {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.r, header[:]); err != nil {
+		return err
+	}
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	// Clients must mask their frames, and control frames must be short
+	// and unfragmented
+	isControl := opcode&0x8 != 0
+	if !masked || length < 0 || header[0]&0x70 != 0 ||
+		(isControl && (length > 125 || header[0]&0x80 == 0)) {
+		ws.sendClose(wsCloseProtocol)
+		return errWebSocketProtocol
+	}
+	if _, err := io.ReadFull(ws.r, ws.mask[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	ws.maskPos = 0
+
+	switch opcode {
+	case wsContinuation, wsText, wsBinary:
+		ws.remaining = length
+		return nil
+	case wsPing, wsPong, wsClose:
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(ws.r, payload); err != nil {
+			return unexpectedEOF(err)
+		}
+		for i := range payload {
+			payload[i] ^= ws.mask[i%4]
+		}
+		switch opcode {
+		case wsPing:
+			return ws.writeFrame(wsPong, payload)
+		case wsClose:
+			ws.sendClose(wsCloseNormal)
+			return io.EOF
+		}
+		return nil
+	default:
+		ws.sendClose(wsCloseProtocol)
+		return errWebSocketProtocol
+	}
+}
+
+// Write sends p as one binary message
+func (ws *wsConn) Write(p []byte) (int, error) {
+	if err := ws.writeFrame(wsBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFrame sends an unfragmented, unmasked frame. Nothing may be sent
+// after a close frame.
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|opcode)
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+
+	if ws.closeSent {
+		return net.ErrClosed
+	}
+	if opcode == wsClose {
+		ws.closeSent = true
+	}
+	_, err := ws.netConn.Write(frame)
+	return err
+}
+
+// sendClose starts the closing handshake with status code. Errors are
+// ignored since the connection is being closed anyway.
+func (ws *wsConn) sendClose(code uint16) {
+	ws.writeFrame(wsClose, binary.BigEndian.AppendUint16(nil, code))
+}
+
+// Close sends a close frame, unless one was sent already, and closes the
+// network connection
+func (ws *wsConn) Close() error {
+	ws.sendClose(wsCloseNormal)
+	return ws.netConn.Close()
+}
+
+// SetReadDeadline sets the deadline of the underlying connection, so that
+// IdleTimeout applies
+func (ws *wsConn) SetReadDeadline(t time.Time) error {
+	return ws.netConn.SetReadDeadline(t)
+}
+
+// unexpectedEOF reports a connection ending inside a frame header
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
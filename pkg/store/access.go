@@ -0,0 +1,195 @@
+package store
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// access holds the access metadata of an entry. It is shared by the copies
+// of the entry in the shard map and updated atomically, so reads can record
+// an access under the shard's read lock.
+type access struct {
+	last atomic.Int64 // unix nanoseconds
+	hits atomic.Int64
+}
+
+// newAccess returns the metadata of an entry written at now
+func newAccess(now time.Time) *access {
+	a := &access{}
+	a.last.Store(now.UnixNano())
+	return a
+}
+
+// touch records a read at now. It does nothing for untracked entries.
+func (a *access) touch(now time.Time) {
+	if a == nil {
+		return
+	}
+	a.last.Store(now.UnixNano())
+	a.hits.Add(1)
+}
+
+// info returns a snapshot of the metadata
+func (a *access) info() AccessInfo {
+	if a == nil {
+		return AccessInfo{}
+	}
+	return AccessInfo{LastAccess: time.Unix(0, a.last.Load()), Hits: a.hits.Load()}
+}
+
+// AccessInfo describes how recently and how often a key has been read
+type AccessInfo struct {
+	// LastAccess is when the key was last read, or written if it has not
+	// been read since
+	LastAccess time.Time
+	// Hits is the number of reads since the key was last written
+	Hits int64
+}
+
+// TrackAccess makes the store record the last access time and number of
+// reads of each key, for AccessInfo, EvictLRU and EvictLFU. Get, GetOrSet
+// and CompareAndSetFunc count as reads; Exists, Range and TTL do not.
+// Writing a key resets its count. It must be called before the store is
+// used and returns s so it can follow New.
+func (s *Store[V]) TrackAccess() *Store[V] {
+	s.trackAccess = true
+	return s
+}
+
+// AccessInfo returns the access metadata of key. The boolean is false if
+// the key does not exist or the store does not track access.
+func (s *Store[V]) AccessInfo(key string) (AccessInfo, bool) {
+	sh := s.shardFor(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	e, exists := sh.items[key]
+	if !exists || e.expired(time.Now()) || e.access == nil {
+		return AccessInfo{}, false
+	}
+	return e.access.info(), true
+}
+
+// EvictionPolicy chooses the keys a store over its LimitKeys limit evicts
+type EvictionPolicy int
+
+const (
+	// EvictLeastRecent evicts the least recently used keys, as EvictLRU
+	EvictLeastRecent EvictionPolicy = iota
+	// EvictLeastFrequent evicts the least frequently used keys, as EvictLFU
+	EvictLeastFrequent
+)
+
+// LimitKeys makes the store evict keys by policy whenever a write takes it
+// over max keys. Each eviction scans the store, so it evicts down to
+// max - max/16 keys to spread that cost over many writes; the key just
+// written is never evicted. It implies TrackAccess, must likewise be called
+// before the store is used, and returns s. Without it, EvictLRU and
+// EvictLFU can be called to evict keys explicitly.
+func (s *Store[V]) LimitKeys(max int, policy EvictionPolicy) *Store[V] {
+	s.trackAccess = true
+	s.maxKeys = max
+	s.eviction = policy
+	return s
+}
+
+// enforceLimit evicts keys if the store holds more than its LimitKeys
+// limit, sparing the entry whose metadata is written
+func (s *Store[V]) enforceLimit(written *access) {
+	if s.maxKeys <= 0 || s.size() <= s.maxKeys {
+		return
+	}
+
+	s.EvictExpired()
+	colder := lessRecent
+	if s.eviction == EvictLeastFrequent {
+		colder = lessFrequent
+	}
+	s.evictColdest(s.size()-(s.maxKeys-s.maxKeys/16), colder, written)
+}
+
+// size returns the number of entries held, counting expired ones not yet
+// removed. Unlike Len it does not visit every entry.
+func (s *Store[V]) size() int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		n += len(sh.items)
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// EvictLRU removes the n least recently used keys and returns the number
+// removed. Keys written before TrackAccess count as the coldest.
+func (s *Store[V]) EvictLRU(n int) int {
+	return s.evictColdest(n, lessRecent, nil)
+}
+
+// EvictLFU removes the n least frequently used keys and returns the number
+// removed. Keys read equally often are removed least recently used first.
+func (s *Store[V]) EvictLFU(n int) int {
+	return s.evictColdest(n, lessFrequent, nil)
+}
+
+// lessRecent orders keys by last access
+func lessRecent(a, b AccessInfo) bool {
+	return a.LastAccess.Before(b.LastAccess)
+}
+
+// lessFrequent orders keys by hits, then by last access
+func lessFrequent(a, b AccessInfo) bool {
+	if a.Hits != b.Hits {
+		return a.Hits < b.Hits
+	}
+	return a.LastAccess.Before(b.LastAccess)
+}
+
+// candidate is a key considered for eviction
+type candidate struct {
+	key    string
+	access *access
+	info   AccessInfo
+}
+
+// evictColdest removes the n live keys ordered first by colder, other than
+// the entry whose metadata is spare if it is not nil. Every shard is
+// scanned under its read lock; a key rewritten between the scan and its
+// removal is kept.
+func (s *Store[V]) evictColdest(n int, colder func(a, b AccessInfo) bool, spare *access) int {
+	if n <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	var candidates []candidate
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for key, e := range sh.items {
+			if !e.expired(now) && (spare == nil || e.access != spare) {
+				candidates = append(candidates, candidate{key: key, access: e.access, info: e.access.info()})
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return colder(candidates[i].info, candidates[j].info)
+	})
+	if n < len(candidates) {
+		candidates = candidates[:n]
+	}
+
+	removed := 0
+	for _, c := range candidates {
+		sh := s.shardFor(c.key)
+		sh.mu.Lock()
+		if e, exists := sh.items[c.key]; exists && e.access == c.access {
+			delete(sh.items, c.key)
+			removed++
+		}
+		sh.mu.Unlock()
+	}
+	return removed
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultEvictionInterval is the sweep interval used when none is given
+const DefaultEvictionInterval = time.Second
+
+// ExpiringStore is a Store whose expired keys are evicted by a background
+// goroutine instead of lingering until they are next accessed.
+type ExpiringStore[V any] struct {
+	*Store[V]
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewExpiring creates a new ExpiringStore that sweeps expired keys every
+// interval. The sweeper runs until Close is called.
+func NewExpiring[V any](interval time.Duration) *ExpiringStore[V] {
+	if interval <= 0 {
+		interval = DefaultEvictionInterval
+	}
+
+	s := &ExpiringStore[V]{
+		Store:    New[V](),
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// run evicts expired keys on every tick until the store is closed
+func (s *ExpiringStore[V]) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.EvictExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background sweeper and waits for it to exit.
+// It is safe to call Close more than once.
+func (s *ExpiringStore[V]) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	return nil
+}
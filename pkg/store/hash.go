@@ -0,0 +1,135 @@
+package store
+
+import (
+	"maps"
+	"time"
+)
+
+// HashStore is a Store of hashes: each key holds a map of fields to
+// values, like a redis hash, whose fields can be read and written
+// individually. Writes copy the hash they change, so the maps returned by
+// Get and passed to Range are never modified afterwards and can be read
+// without locking; they must not be modified by the caller. Writes are
+// therefore linear in the size of the hash, which suits records of a few
+// dozen fields.
+type HashStore struct {
+	*Store[map[string]string]
+}
+
+// NewHash creates a new HashStore with DefaultShards shards
+func NewHash() *HashStore {
+	return &HashStore{Store: New[map[string]string]()}
+}
+
+// HSet sets field of the hash under key to value, creating the hash if
+// needed, and reports whether the field is new
+func (h *HashStore) HSet(key, field, value string) bool {
+	return h.HSetFields(key, map[string]string{field: value}) == 1
+}
+
+// HSetFields sets several fields of the hash under key at once, creating
+// the hash if needed, and returns the number of fields that are new
+func (h *HashStore) HSetFields(key string, fields map[string]string) int {
+	return h.update(key, func(hash map[string]string) int {
+		added := 0
+		for field, value := range fields {
+			if _, exists := hash[field]; !exists {
+				added++
+			}
+			hash[field] = value
+		}
+		return added
+	})
+}
+
+// HGet returns the value of field in the hash under key
+func (h *HashStore) HGet(key, field string) (string, bool) {
+	hash, exists := h.Get(key)
+	if !exists {
+		return "", false
+	}
+	value, exists := hash[field]
+	return value, exists
+}
+
+// HGetAll returns a copy of the hash under key, or nil if there is none
+func (h *HashStore) HGetAll(key string) map[string]string {
+	hash, _ := h.Get(key)
+	return maps.Clone(hash)
+}
+
+// HDel removes fields from the hash under key and returns the number that
+// were present. A hash left without fields is removed, as in redis.
+func (h *HashStore) HDel(key string, fields ...string) int {
+	return h.update(key, func(hash map[string]string) int {
+		removed := 0
+		for _, field := range fields {
+			if _, exists := hash[field]; exists {
+				delete(hash, field)
+				removed++
+			}
+		}
+		return removed
+	})
+}
+
+// HExists reports whether the hash under key has field. Like Exists it
+// does not count as an access.
+func (h *HashStore) HExists(key, field string) bool {
+	sh := h.shardFor(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	e, exists := sh.items[key]
+	if !exists || e.expired(time.Now()) {
+		return false
+	}
+	_, exists = e.value[field]
+	return exists
+}
+
+// HLen returns the number of fields of the hash under key
+func (h *HashStore) HLen(key string) int {
+	hash, _ := h.Get(key)
+	return len(hash)
+}
+
+// update applies fn to a copy of the hash under key, or to an empty hash,
+// and stores the result under the shard's lock, keeping the key's expiry.
+// It returns what fn returns. Nothing is written if fn changes nothing,
+// and an emptied hash is removed.
+func (h *HashStore) update(key string, fn func(hash map[string]string) int) int {
+	sh := h.shardFor(key)
+	sh.mu.Lock()
+
+	now := time.Now()
+	e, exists := sh.items[key]
+	if !exists || e.expired(now) {
+		e = entry[map[string]string]{}
+	}
+
+	hash := make(map[string]string, len(e.value)+1)
+	for field, value := range e.value {
+		hash[field] = value
+	}
+	n := fn(hash)
+	switch {
+	case len(hash) == 0:
+		delete(sh.items, key)
+		sh.mu.Unlock()
+		return n
+	case maps.Equal(hash, e.value):
+		sh.mu.Unlock()
+		return n
+	}
+
+	e.value = hash
+	if h.trackAccess {
+		e.access = newAccess(now)
+	}
+	sh.items[key] = e
+	sh.mu.Unlock()
+
+	h.enforceLimit(e.access)
+	return n
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// KeyedMutex locks individual keys so that operations on unrelated keys
+// do not wait for each other. Keys are mapped by hash onto a fixed set of
+// mutexes, which bounds memory regardless of the number of keys; keys that
+// share a mutex are serialized.
+//
+// A goroutine must not hold more than one key at a time: two keys may map
+// to the same mutex, so locking a second key can deadlock.
+type KeyedMutex struct {
+	shards []sync.RWMutex
+}
+
+// NewKeyedMutex creates a KeyedMutex backed by n mutexes
+func NewKeyedMutex(n int) *KeyedMutex {
+	if n < 1 {
+		n = 1
+	}
+	return &KeyedMutex{shards: make([]sync.RWMutex, n)}
+}
+
+// mutexFor returns the mutex guarding key
+func (m *KeyedMutex) mutexFor(key string) *sync.RWMutex {
+	return &m.shards[hashKey(key)%uint32(len(m.shards))]
+}
+
+// Lock locks key for writing
+func (m *KeyedMutex) Lock(key string) {
+	m.mutexFor(key).Lock()
+}
+
+// Unlock unlocks key for writing
+func (m *KeyedMutex) Unlock(key string) {
+	m.mutexFor(key).Unlock()
+}
+
+// RLock locks key for reading
+func (m *KeyedMutex) RLock(key string) {
+	m.mutexFor(key).RLock()
+}
+
+// RUnlock unlocks key for reading
+func (m *KeyedMutex) RUnlock(key string) {
+	m.mutexFor(key).RUnlock()
+}
+
+// hashKey returns the FNV-1a hash of key
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
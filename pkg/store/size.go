@@ -0,0 +1,105 @@
+package store
+
+import (
+	"reflect"
+	"time"
+)
+
+// Sizer is implemented by values that can estimate their own memory usage
+type Sizer interface {
+	// ApproxSize returns the approximate number of bytes used by the value,
+	// including memory it references
+	ApproxSize() int
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ApproxSize estimates the number of bytes used by v and the memory it
+// references. Values implementing Sizer report their own size. Otherwise
+// strings, slices, maps, pointers and structs are walked: slices count
+// their capacity and maps a per-entry overhead. Memory reachable through
+// several pointers is counted once. A time.Time's location is shared and
+// not counted.
+func ApproxSize(v interface{}) int {
+	if v == nil {
+		return 0
+	}
+	if s, ok := v.(Sizer); ok {
+		return s.ApproxSize()
+	}
+	val := reflect.ValueOf(v)
+	return int(val.Type().Size()) + indirectSize(val, make(map[uintptr]bool))
+}
+
+// mapEntryOverhead approximates the bucket bookkeeping per map entry
+const mapEntryOverhead = 8
+
+// indirectSize returns the size of the memory v references, not counting
+// v itself
+func indirectSize(v reflect.Value, seen map[uintptr]bool) int {
+	switch v.Kind() {
+	case reflect.String:
+		return v.Len()
+
+	case reflect.Slice:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		size := v.Cap() * int(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			size += indirectSize(v.Index(i), seen)
+		}
+		return size
+
+	case reflect.Array:
+		size := 0
+		for i := 0; i < v.Len(); i++ {
+			size += indirectSize(v.Index(i), seen)
+		}
+		return size
+
+	case reflect.Map:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		entry := int(v.Type().Key().Size()+v.Type().Elem().Size()) + mapEntryOverhead
+		size := v.Len() * entry
+		iter := v.MapRange()
+		for iter.Next() {
+			size += indirectSize(iter.Key(), seen) + indirectSize(iter.Value(), seen)
+		}
+		return size
+
+	case reflect.Pointer:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		if v.CanInterface() {
+			if s, ok := v.Interface().(Sizer); ok {
+				return s.ApproxSize()
+			}
+		}
+		return int(v.Type().Elem().Size()) + indirectSize(v.Elem(), seen)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		elem := v.Elem()
+		return int(elem.Type().Size()) + indirectSize(elem, seen)
+
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return 0
+		}
+		size := 0
+		for i := 0; i < v.NumField(); i++ {
+			size += indirectSize(v.Field(i), seen)
+		}
+		return size
+	}
+	return 0
+}
@@ -0,0 +1,24 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+)
+
+// SortedKeys returns the keys of m in ascending order. Handlers replying
+// from a map use it so that identical data always gives the same reply.
+func SortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// SortedKeys returns all live keys in ascending order
+func (s *Store[V]) SortedKeys() []string {
+	keys := s.Keys()
+	slices.Sort(keys)
+	return keys
+}
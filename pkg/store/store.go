@@ -0,0 +1,316 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultShards is the number of shards used by New
+const DefaultShards = 16
+
+// entry holds a stored value and its optional expiry time
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time // zero means no expiry
+	access    *access   // nil unless the store tracks access
+}
+
+// expired reports whether the entry has expired at the given time
+func (e entry[V]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// shard is a single lock-protected partition of the keyspace
+type shard[V any] struct {
+	items map[string]entry[V]
+	mu    sync.RWMutex
+}
+
+// Store is a goroutine-safe key/value store. Keys are spread across
+// independently locked shards by hash to reduce lock contention.
+type Store[V any] struct {
+	shards      []*shard[V]
+	trackAccess bool
+	maxKeys     int // zero means no limit
+	eviction    EvictionPolicy
+}
+
+// New creates a new Store with DefaultShards shards
+func New[V any]() *Store[V] {
+	return NewSharded[V](DefaultShards)
+}
+
+// NewSharded creates a new Store with n shards
+func NewSharded[V any](n int) *Store[V] {
+	if n < 1 {
+		n = 1
+	}
+
+	s := &Store[V]{shards: make([]*shard[V], n)}
+	for i := range s.shards {
+		s.shards[i] = &shard[V]{items: make(map[string]entry[V])}
+	}
+	return s
+}
+
+// shardFor returns the shard owning the given key
+func (s *Store[V]) shardFor(key string) *shard[V] {
+	return s.shards[hashKey(key)%uint32(len(s.shards))]
+}
+
+// Get returns the value stored under key. Expired keys are reported as missing.
+func (s *Store[V]) Get(key string) (V, bool) {
+	sh := s.shardFor(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	now := time.Now()
+	e, exists := sh.items[key]
+	if !exists || e.expired(now) {
+		var zero V
+		return zero, false
+	}
+	e.access.touch(now)
+	return e.value, true
+}
+
+// Exists reports whether key holds a value that has not expired. Unlike
+// Get it does not count as an access.
+func (s *Store[V]) Exists(key string) bool {
+	sh := s.shardFor(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	e, exists := sh.items[key]
+	return exists && !e.expired(time.Now())
+}
+
+// Set stores value under key with no expiry
+func (s *Store[V]) Set(key string, value V) {
+	s.set(key, entry[V]{value: value})
+}
+
+// SetWithTTL stores value under key and expires it after ttl.
+// A non-positive ttl stores the value with no expiry.
+func (s *Store[V]) SetWithTTL(key string, value V, ttl time.Duration) {
+	e := entry[V]{value: value}
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl)
+	}
+	s.set(key, e)
+}
+
+// set writes an entry into the owning shard
+func (s *Store[V]) set(key string, e entry[V]) {
+	if s.trackAccess {
+		e.access = newAccess(time.Now())
+	}
+
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	sh.items[key] = e
+	sh.mu.Unlock()
+
+	s.enforceLimit(e.access)
+}
+
+// GetOrSet returns the live value stored under key if there is one.
+// Otherwise it stores value with no expiry and returns it. The boolean is
+// true if the value was already present.
+func (s *Store[V]) GetOrSet(key string, value V) (V, bool) {
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+
+	now := time.Now()
+	if e, exists := sh.items[key]; exists && !e.expired(now) {
+		e.access.touch(now)
+		sh.mu.Unlock()
+		return e.value, true
+	}
+	e := entry[V]{value: value}
+	if s.trackAccess {
+		e.access = newAccess(now)
+	}
+	sh.items[key] = e
+	sh.mu.Unlock()
+
+	s.enforceLimit(e.access)
+	return value, false
+}
+
+// CompareAndSetFunc stores new under key if the key holds a live value
+// that equal reports as equal to old, and reports whether it did. The
+// check and the write happen under the shard's lock, so no other write to
+// the key can come in between. The key's expiry is left unchanged.
+func (s *Store[V]) CompareAndSetFunc(key string, old, new V, equal func(a, b V) bool) bool {
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	now := time.Now()
+	e, exists := sh.items[key]
+	if !exists || e.expired(now) || !equal(e.value, old) {
+		return false
+	}
+	e.access.touch(now)
+	e.value = new
+	sh.items[key] = e
+	return true
+}
+
+// CompareAndSet is CompareAndSetFunc for comparable values, using ==. It
+// is a function rather than a method because Store allows values that
+// are not comparable.
+func CompareAndSet[V comparable](s *Store[V], key string, old, new V) bool {
+	return s.CompareAndSetFunc(key, old, new, func(a, b V) bool { return a == b })
+}
+
+// Delete removes key and reports whether a live value was removed
+func (s *Store[V]) Delete(key string) bool {
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	e, exists := sh.items[key]
+	if !exists {
+		return false
+	}
+	delete(sh.items, key)
+	return !e.expired(time.Now())
+}
+
+// Clear removes every key
+func (s *Store[V]) Clear() {
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		sh.items = make(map[string]entry[V])
+		sh.mu.Unlock()
+	}
+}
+
+// Keys returns all live keys in no particular order
+func (s *Store[V]) Keys() []string {
+	var keys []string
+	s.Range(func(key string, _ V) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// Len returns the number of live keys
+func (s *Store[V]) Len() int {
+	n := 0
+	s.Range(func(string, V) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// Range calls fn for each live key/value pair until fn returns false.
+// Each shard is read-locked while it is visited, so fn must not modify the store.
+func (s *Store[V]) Range(fn func(key string, value V) bool) {
+	now := time.Now()
+	for _, sh := range s.shards {
+		if !sh.rangeItems(now, fn) {
+			return
+		}
+	}
+}
+
+// rangeItems visits the live entries of a single shard
+func (sh *shard[V]) rangeItems(now time.Time, fn func(key string, value V) bool) bool {
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	for key, e := range sh.items {
+		if e.expired(now) {
+			continue
+		}
+		if !fn(key, e.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Expire sets a ttl on an existing live key and reports whether the key exists.
+// A non-positive ttl removes any expiry.
+func (s *Store[V]) Expire(key string, ttl time.Duration) bool {
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	e, exists := sh.items[key]
+	if !exists || e.expired(time.Now()) {
+		return false
+	}
+
+	e.expiresAt = time.Time{}
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl)
+	}
+	sh.items[key] = e
+	return true
+}
+
+// TTL returns the remaining time to live for key. The boolean is false if the
+// key does not exist; a zero duration with true means the key has no expiry.
+func (s *Store[V]) TTL(key string) (time.Duration, bool) {
+	sh := s.shardFor(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	now := time.Now()
+	e, exists := sh.items[key]
+	if !exists || e.expired(now) {
+		return 0, false
+	}
+	if e.expiresAt.IsZero() {
+		return 0, true
+	}
+	return e.expiresAt.Sub(now), true
+}
+
+// EvictExpired removes expired entries from every shard and returns the
+// number removed. Expired entries are otherwise kept until overwritten or
+// deleted, unless an ExpiringStore sweeps them. Each shard is scanned under
+// its read lock and the write lock is only taken to delete the keys found,
+// so readers are not blocked for the duration of the scan.
+func (s *Store[V]) EvictExpired() int {
+	removed := 0
+	for _, sh := range s.shards {
+		removed += sh.evictExpired(time.Now())
+	}
+	return removed
+}
+
+// evictExpired removes expired entries from a single shard
+func (sh *shard[V]) evictExpired(now time.Time) int {
+	var expired []string
+	sh.mu.RLock()
+	for key, e := range sh.items {
+		if e.expired(now) {
+			expired = append(expired, key)
+		}
+	}
+	sh.mu.RUnlock()
+
+	if len(expired) == 0 {
+		return 0
+	}
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	removed := 0
+	for _, key := range expired {
+		// The key may have been rewritten since the scan
+		if e, exists := sh.items[key]; exists && e.expired(now) {
+			delete(sh.items, key)
+			removed++
+		}
+	}
+	return removed
+}
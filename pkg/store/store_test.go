@@ -0,0 +1,110 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCompareAndSet has many goroutines increment one counter by CAS, so
+// that every increment reads the value another may be writing. Run it with
+// -race.
+func TestCompareAndSet(t *testing.T) {
+	s := New[int]()
+	s.Set("counter", 0)
+
+	const goroutines, attempts = 32, 1000
+	var successes atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < attempts; j++ {
+				old, _ := s.Get("counter")
+				if CompareAndSet(s, "counter", old, old+1) {
+					successes.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := s.Get("counter")
+	if n := successes.Load(); int64(got) != n || n == 0 {
+		t.Errorf("counter = %d after %d successful CAS", got, n)
+	}
+}
+
+func TestCompareAndSetMissing(t *testing.T) {
+	s := New[string]()
+	if CompareAndSet(s, "key", "", "new") {
+		t.Error("CompareAndSet on a missing key succeeded")
+	}
+	if _, exists := s.Get("key"); exists {
+		t.Error("CompareAndSet on a missing key stored a value")
+	}
+
+	s.Set("key", "old")
+	if CompareAndSet(s, "key", "other", "new") {
+		t.Error("CompareAndSet with a stale old value succeeded")
+	}
+	if !CompareAndSet(s, "key", "old", "new") {
+		t.Error("CompareAndSet with the current value failed")
+	}
+	if v, _ := s.Get("key"); v != "new" {
+		t.Errorf("value = %q, want new", v)
+	}
+}
+
+func TestLimitKeys(t *testing.T) {
+	s := New[int]().LimitKeys(32, EvictLeastRecent)
+	for i := 0; i < 32; i++ {
+		s.Set(fmt.Sprint(i), i)
+	}
+	for i := 16; i < 32; i++ {
+		s.Get(fmt.Sprint(i))
+	}
+	if n := s.Len(); n != 32 {
+		t.Fatalf("Len = %d at the limit, want 32", n)
+	}
+
+	s.Set("new", 0)
+	if n := s.Len(); n != 30 {
+		t.Errorf("Len = %d after going over the limit, want 30", n)
+	}
+	for _, key := range []string{"new", "16", "31"} {
+		if !s.Exists(key) {
+			t.Errorf("recently used key %s was evicted", key)
+		}
+	}
+	for _, key := range []string{"0", "1", "2"} {
+		if s.Exists(key) {
+			t.Errorf("least recently used key %s was kept", key)
+		}
+	}
+}
+
+func TestLimitKeysLFU(t *testing.T) {
+	s := New[int]().LimitKeys(2, EvictLeastFrequent)
+	s.Set("a", 1)
+	s.Set("b", 2)
+	s.Get("a")
+	s.Get("b")
+	s.Get("b")
+
+	// A new key has no reads, but the write that adds it must not evict it
+	s.Set("c", 3)
+	if s.Exists("a") || !s.Exists("b") || !s.Exists("c") {
+		t.Errorf("keys = %v, want b and c", s.Keys())
+	}
+
+	h := NewHash()
+	h.LimitKeys(1, EvictLeastFrequent)
+	h.HSet("x", "f", "1")
+	h.HSet("y", "f", "1")
+	if h.Exists("x") || !h.Exists("y") {
+		t.Errorf("hash keys = %v, want y", h.Keys())
+	}
+}